@@ -0,0 +1,278 @@
+package multilogger
+
+import (
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultAsyncQueueSize = 1024
+
+// OverflowPolicy determines what an asynchronous hook does when its queue is full.
+type OverflowPolicy int
+
+const (
+	// BlockOnOverflow waits until there is room in the queue.
+	BlockOnOverflow OverflowPolicy = iota
+	// DropOldest removes the oldest entry from the queue to make room for the new one.
+	DropOldest
+	// DropNewest discards the new entry.
+	DropNewest
+	// DropBelowLevel discards the new entry if it is less severe than AsyncOptions.DropLevel (including
+	// outputs sent through Print), otherwise, it waits until there is room in the queue.
+	DropBelowLevel
+)
+
+// AsyncOptions represents the options used to configure an asynchronous hook.
+type AsyncOptions struct {
+	// QueueSize is the maximum number of entries waiting to be processed (default is 1024).
+	QueueSize int
+	// Overflow is the policy applied when the queue is full (default is BlockOnOverflow).
+	Overflow OverflowPolicy
+	// DropLevel is the least severe level that is never dropped with the DropBelowLevel policy, the less
+	// severe entries could be dropped. Note that the default value (PanicLevel) makes all levels except
+	// panic droppable.
+	DropLevel logrus.Level
+}
+
+// NewAsyncHook wraps a hook to process entries in background. The resulting hook has the same name and
+// level as the inner hook. Errors are reported to the logger and pending entries are processed when the
+// hook is flushed or closed. Entries logged after the hook has been closed are processed synchronously.
+func NewAsyncHook(inner *Hook, options AsyncOptions) *Hook {
+	if options.QueueSize <= 0 {
+		options.QueueSize = defaultAsyncQueueSize
+	}
+	result := NewHook(inner.name, inner.level, newAsyncHook(inner, options))
+	result.noRedaction = inner.noRedaction
+	return result
+}
+
+func newAsyncHook(inner *Hook, options AsyncOptions) *asyncHook {
+	hook := &asyncHook{
+		inner:   inner,
+		options: options,
+		queue:   make(chan *logrus.Entry, options.QueueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	hook.cond = sync.NewCond(&hook.mutex)
+	go hook.run()
+	return hook
+}
+
+// clone creates a new asynchronous hook (with its own queue) around a clone of the inner hook.
+func (hook *asyncHook) clone() logrus.Hook {
+	hook.fireMutex.Lock()
+	defer hook.fireMutex.Unlock()
+	inner := hook.inner.inner
+	if cloneable, ok := inner.(cloneable); ok {
+		inner = cloneable.clone()
+	}
+	newInner := NewHook(hook.inner.name, hook.inner.level, inner)
+	newInner.noRedaction = hook.inner.noRedaction
+	return newAsyncHook(newInner, hook.options)
+}
+
+type asyncI interface {
+	Dropped() uint64
+}
+
+type asyncHook struct {
+	inner   *Hook
+	options AsyncOptions
+	queue   chan *logrus.Entry
+	stop    chan struct{}
+	done    chan struct{}
+
+	fireMutex sync.Mutex // Prevents the inner hook from being modified while it is fired
+
+	closeMutex sync.RWMutex // Prevents the hook from being closed while an entry is being enqueued
+	closed     bool
+
+	mutex     sync.Mutex // Protects the following attributes
+	cond      *sync.Cond // Signaled each time an entry is processed or dropped
+	logger    *Logger
+	enqueued  uint64
+	processed uint64
+	dropped   uint64
+}
+
+func (hook *asyncHook) Fire(entry *logrus.Entry) error {
+	entry = copyEntry(entry)
+	if _, isSet := entry.Data[goroutineFieldName]; !isSet && usesToken(hook.inner, entry.Level, tokenGoroutine) {
+		// We keep track of the goroutine that logged the entry since it will be formatted by another one
+		entry.Data[goroutineFieldName] = goroutineID()
+	}
+	hook.closeMutex.RLock()
+	defer hook.closeMutex.RUnlock()
+	if hook.closed {
+		return hook.fireInner(entry)
+	}
+	hook.mutex.Lock()
+	hook.enqueued++
+	hook.mutex.Unlock()
+
+	switch hook.options.Overflow {
+	case DropOldest:
+		for !hook.tryEnqueue(entry) {
+			select {
+			case <-hook.queue:
+				hook.markProcessed(true)
+			default:
+			}
+		}
+	case DropNewest:
+		if !hook.tryEnqueue(entry) {
+			hook.markProcessed(true)
+		}
+	case DropBelowLevel:
+		if entry.Level > hook.options.DropLevel {
+			if !hook.tryEnqueue(entry) {
+				hook.markProcessed(true)
+			}
+			return nil
+		}
+		fallthrough
+	default:
+		// The queue is consumed until the hook is closed, which cannot happen before we return
+		hook.queue <- entry
+	}
+	return nil
+}
+
+func (hook *asyncHook) tryEnqueue(entry *logrus.Entry) bool {
+	select {
+	case hook.queue <- entry:
+		return true
+	default:
+		return false
+	}
+}
+
+func (hook *asyncHook) run() {
+	defer close(hook.done)
+	for {
+		select {
+		case entry := <-hook.queue:
+			hook.process(entry)
+		case <-hook.stop:
+			for {
+				select {
+				case entry := <-hook.queue:
+					hook.process(entry)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (hook *asyncHook) fireInner(entry *logrus.Entry) error {
+	hook.fireMutex.Lock()
+	defer hook.fireMutex.Unlock()
+	return hook.inner.Fire(entry)
+}
+
+func (hook *asyncHook) process(entry *logrus.Entry) {
+	err := hook.fireInner(entry)
+	if _, reported := hook.inner.inner.(setLoggerI); !reported && err != nil {
+		// Hooks that are not attached to the logger cannot report their own errors
+		if logger := hook.getLogger(); logger != nil {
+			logger.AddError(err)
+		}
+	}
+	hook.markProcessed(false)
+}
+
+// markProcessed indicates that an entry has been processed or dropped.
+func (hook *asyncHook) markProcessed(dropped bool) {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	hook.processed++
+	if dropped {
+		hook.dropped++
+	}
+	hook.cond.Broadcast()
+}
+
+func (hook *asyncHook) getLogger() *Logger {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	return hook.logger
+}
+
+// Flush waits until all entries queued before the call have been processed and flushes the inner hook.
+func (hook *asyncHook) Flush() error {
+	hook.mutex.Lock()
+	for target := hook.enqueued; hook.processed < target; {
+		hook.cond.Wait()
+	}
+	hook.mutex.Unlock()
+	return hook.inner.Flush()
+}
+
+// Close processes the pending entries, stops the background processing and closes the inner hook.
+func (hook *asyncHook) Close() error {
+	hook.closeMutex.Lock()
+	if !hook.closed {
+		hook.closed = true
+		close(hook.stop)
+	}
+	hook.closeMutex.Unlock()
+	<-hook.done
+	return hook.inner.Close()
+}
+
+// Dropped returns the number of entries that have been dropped because the queue was full.
+func (hook *asyncHook) Dropped() uint64 {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	return hook.dropped
+}
+
+func (hook *asyncHook) Levels() []logrus.Level { return nil }
+func (hook *asyncHook) Reopen() error          { return hook.inner.Reopen() }
+
+func (hook *asyncHook) SetLogger(logger *Logger) {
+	hook.mutex.Lock()
+	hook.logger = logger
+	hook.mutex.Unlock()
+	if sl, ok := hook.inner.inner.(setLoggerI); ok {
+		hook.fireMutex.Lock()
+		defer hook.fireMutex.Unlock()
+		sl.SetLogger(logger)
+	}
+}
+
+func (hook *asyncHook) Formatter() logrus.Formatter { return hook.inner.GetFormatter() }
+
+func (hook *asyncHook) SetFormatter(formatter logrus.Formatter) {
+	hook.fireMutex.Lock()
+	defer hook.fireMutex.Unlock()
+	hook.inner.SetFormatter(formatter)
+}
+
+func (hook *asyncHook) SetOut(out io.Writer) {
+	hook.fireMutex.Lock()
+	defer hook.fireMutex.Unlock()
+	hook.inner.SetOut(out)
+}
+
+func (hook *asyncHook) SetStdout(out io.Writer) {
+	hook.fireMutex.Lock()
+	defer hook.fireMutex.Unlock()
+	hook.inner.SetStdout(out)
+}
+
+// copyEntry duplicates the entry since it could be modified once the hook returns.
+func copyEntry(entry *logrus.Entry) *logrus.Entry {
+	result := *entry
+	result.Buffer = nil
+	result.Data = make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		result.Data[key] = value
+	}
+	return &result
+}
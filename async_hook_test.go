@@ -0,0 +1,177 @@
+package multilogger
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingHook is a hook that waits to be released before processing entries.
+type blockingHook struct {
+	started  chan string
+	release  chan struct{}
+	messages []string
+	err      error
+}
+
+func newBlockingHook() *blockingHook {
+	return &blockingHook{started: make(chan string, 100), release: make(chan struct{})}
+}
+
+func (hook *blockingHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (hook *blockingHook) Fire(entry *logrus.Entry) error {
+	hook.started <- entry.Message
+	<-hook.release
+	hook.messages = append(hook.messages, entry.Message)
+	return hook.err
+}
+
+func TestAsyncHook(t *testing.T) {
+	var out bytes.Buffer
+	log := getTestLogger("async")
+	log.AddHooks(NewAsyncHook(NewConsoleHook("async", logrus.InfoLevel, "%module% %message%").SetOut(&out), AsyncOptions{}))
+	child := log.Child("child").WithField("key", "value")
+
+	for i := 1; i <= 3; i++ {
+		log.Infof("message %d", i)
+		child.Debugf("debug %d", i)
+		child.Warningf("child %d", i)
+	}
+	assert.NoError(t, log.Flush())
+	assert.NoError(t, log.GetError())
+	assert.Equal(t, "async message 1\nasync:child child 1\nasync message 2\nasync:child child 2\nasync message 3\nasync:child child 3\n", out.String())
+
+	// After close, entries are processed synchronously
+	assert.NoError(t, log.Close())
+	log.Info("after close")
+	assert.Equal(t, "async message 1\nasync:child child 1\nasync message 2\nasync:child child 2\nasync message 3\nasync:child child 3\nasync after close\n", out.String())
+	assert.Equal(t, uint64(0), log.Hook("async").Dropped())
+}
+
+func TestAsyncHook_Copy(t *testing.T) {
+	inner := newBlockingHook()
+	inner.err = fmt.Errorf("inner error")
+	close(inner.release)
+	log := getTestLogger("async").AddHooks(NewAsyncHook(NewHook("blocking", logrus.InfoLevel, inner), AsyncOptions{}))
+
+	// The copy has its own asynchronous hook, closing it does not affect the original logger
+	copied := log.Copy()
+	assert.NotSame(t, log.Hook("blocking").GetInnerHook(), copied.Hook("blocking").GetInnerHook())
+	assert.NoError(t, copied.Close())
+	assert.True(t, copied.Hook("blocking").GetInnerHook().(*asyncHook).closed)
+	assert.False(t, log.Hook("blocking").GetInnerHook().(*asyncHook).closed)
+
+	// The errors are reported to the logger that logged the entry
+	log.Info("Hello")
+	assert.NoError(t, log.Flush())
+	assert.ErrorContains(t, log.GetError(), "inner error")
+	assert.NoError(t, copied.GetError())
+}
+
+func TestAsyncHook_Goroutine(t *testing.T) {
+	var out bytes.Buffer
+	log := New("async", NewAsyncHook(NewConsoleHook("async", logrus.InfoLevel, "%goroutine% %message%").SetOut(&out), AsyncOptions{}))
+
+	// The goroutine of the caller is captured since the entry is formatted by another goroutine
+	log.Info("Hello")
+	assert.NoError(t, log.Flush())
+	assert.Equal(t, fmt.Sprintf("%d Hello\n", goroutineID()), out.String())
+}
+
+func TestAsyncHook_Overflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  AsyncOptions
+		send     []logrus.Level
+		expected []string
+		dropped  uint64
+	}{
+		{"Drop newest", AsyncOptions{QueueSize: 1, Overflow: DropNewest},
+			[]logrus.Level{logrus.InfoLevel, logrus.InfoLevel, logrus.InfoLevel, logrus.ErrorLevel},
+			[]string{"message 1", "message 2"}, 2},
+		{"Drop oldest", AsyncOptions{QueueSize: 1, Overflow: DropOldest},
+			[]logrus.Level{logrus.InfoLevel, logrus.InfoLevel, logrus.InfoLevel, logrus.ErrorLevel},
+			[]string{"message 1", "message 4"}, 2},
+		{"Drop below level", AsyncOptions{QueueSize: 2, Overflow: DropBelowLevel, DropLevel: logrus.WarnLevel},
+			[]logrus.Level{logrus.InfoLevel, logrus.WarnLevel, logrus.ErrorLevel, logrus.DebugLevel},
+			[]string{"message 1", "message 2", "message 3"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := newBlockingHook()
+			hook := NewAsyncHook(NewHook("blocking", logrus.TraceLevel, inner), tt.options)
+			log := getTestLogger("async").AddHooks(hook)
+
+			for i, level := range tt.send {
+				log.Logf(level, "message %d", i+1)
+				if i == 0 {
+					// We wait for the first message to be processed to ensure that the queue is empty
+					assert.Equal(t, "message 1", <-inner.started)
+				}
+			}
+			assert.Equal(t, tt.dropped, hook.Dropped())
+			close(inner.release)
+			assert.NoError(t, log.Flush())
+			assert.Equal(t, tt.expected, inner.messages)
+		})
+	}
+}
+
+func TestAsyncHook_Errors(t *testing.T) {
+	inner := newBlockingHook()
+	inner.err = fmt.Errorf("inner error")
+	close(inner.release)
+	log := getTestLogger("async")
+	log.AddHooks(NewAsyncHook(NewHook("blocking", logrus.InfoLevel, inner), AsyncOptions{}))
+	log.AddHooks(NewAsyncHook(NewConsoleHook("console", logrus.InfoLevel).SetOut(&buggyWriter{fmt.Errorf("Disk is full")}), AsyncOptions{}))
+
+	log.Info("Hello")
+	assert.NoError(t, log.Flush())
+	assert.ErrorContains(t, log.GetError(), "ConsoleHook: Disk is full")
+	assert.ErrorContains(t, log.GetError(), "inner error")
+}
+
+func TestAsyncHook_ConcurrentClose(t *testing.T) {
+	const count = 1000
+	inner := &countingHook{}
+	hook := NewAsyncHook(NewHook("counting", logrus.InfoLevel, inner), AsyncOptions{QueueSize: 4 * count, Overflow: DropNewest})
+	log := getTestLogger("async").AddHooks(hook)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				log.Info("message")
+			}
+		}()
+	}
+	for inner.count.Load() < count {
+		// We close the hook while the entries are being logged
+		runtime.Gosched()
+	}
+	assert.NoError(t, hook.Close())
+	wg.Wait()
+
+	// No entry is lost and Flush does not wait for entries that have never been queued
+	assert.NoError(t, log.Flush())
+	assert.Equal(t, int64(4*count), inner.count.Load())
+}
+
+// countingHook is a hook that counts the entries it receives.
+type countingHook struct{ count atomic.Int64 }
+
+func (hook *countingHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (hook *countingHook) Fire(*logrus.Entry) error {
+	hook.count.Add(1)
+	return nil
+}
@@ -10,6 +10,7 @@ const (
 	// CallerEnvVar is an environment variable that enable the caller stack by default.
 	CallerEnvVar = "MULTILOGGER_CALLER"
 	// FormatEnvVar is an environment variable that allows users to set the default format used for log entry.
+	// Specific formats could be defined for some levels, i.e. "@default=%message%||@error,fatal=%message% %caller%".
 	FormatEnvVar = "MULTILOGGER_FORMAT"
 	// FormatFileEnvVar is an environment variable that allows users to set the default format used for log entry using a file logger.
 	FormatFileEnvVar = "MULTILOGGER_FILE_FORMAT"
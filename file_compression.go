@@ -0,0 +1,140 @@
+package multilogger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"sync"
+)
+
+// Compression represents an algorithm used to compress the rotated log files.
+//
+// An existing archive is never overwritten, the compressed content is appended to it. So the format must
+// support concatenated streams (as gzip members or zstd frames do).
+//
+// Other algorithms can be plugged by providing the extension and a writer factory, i.e. for zstd:
+//
+//	Compression{".zst", func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }}
+type Compression struct {
+	Extension string
+	NewWriter func(io.Writer) (io.WriteCloser, error)
+}
+
+// GzipCompression compresses rotated files with gzip.
+var GzipCompression = Compression{
+	Extension: ".gz",
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+}
+
+// compress queues the compression of a rotated file, report is called if an error occurs.
+func (target *fileTarget) compress(filename string, compression *Compression, report func(error)) {
+	target.compressions.push(func() {
+		if err := compressFile(filename, compression); err != nil && report != nil {
+			report(err)
+		}
+	})
+}
+
+// compressionQueue processes the compressions of a target sequentially in background. The jobs never
+// run concurrently, so a backup cannot be renamed by a job while it is compressed by another one.
+type compressionQueue struct {
+	mutex   sync.Mutex
+	done    *sync.Cond // Signaled when the queue becomes empty
+	jobs    []func()
+	running bool
+}
+
+// push adds a job to the queue and starts the worker if it is not already running.
+func (queue *compressionQueue) push(job func()) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.jobs = append(queue.jobs, job)
+	if !queue.running {
+		queue.running = true
+		go queue.run()
+	}
+}
+
+// run processes the jobs until the queue is empty.
+func (queue *compressionQueue) run() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for len(queue.jobs) > 0 {
+		job := queue.jobs[0]
+		queue.jobs = queue.jobs[1:]
+		queue.mutex.Unlock()
+		job()
+		queue.mutex.Lock()
+	}
+	queue.running = false
+	if queue.done != nil {
+		queue.done.Broadcast()
+	}
+}
+
+// wait blocks until all the queued jobs have been processed.
+func (queue *compressionQueue) wait() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.done == nil {
+		queue.done = sync.NewCond(&queue.mutex)
+	}
+	for queue.running {
+		queue.done.Wait()
+	}
+}
+
+func compressFile(filename string, compression *Compression) (err error) {
+	source, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		// The file has been removed by the retention policy before we get a chance to compress it
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	// If the archive already exists (i.e. the file has been reopened by another run), we append to it
+	compressed := filename + compression.Extension
+	out, err := os.OpenFile(compressed, os.O_CREATE|os.O_APPEND|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	existing, err := out.Stat()
+	if err != nil {
+		out.Close()
+		return err
+	}
+	defer func() {
+		if err != nil {
+			// We restore the archive as it was before the compression
+			if existing.Size() == 0 {
+				os.Remove(compressed)
+			} else {
+				out.Truncate(existing.Size())
+			}
+		}
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	writer, err := compression.NewWriter(out)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(writer, source); err != nil {
+		writer.Close()
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	source.Close()
+	// The source could have been deleted by the retention policy while we were compressing it
+	return removeIfExists(filename)
+}
@@ -0,0 +1,104 @@
+package multilogger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultFileHeader is the header written by file hooks each time they open a file.
+	DefaultFileHeader = "# {{ .Timestamp }}\n"
+	fileHeaderMessage = "Log file opened"
+)
+
+var defaultFileHeader = template.Must(template.New("header").Parse(DefaultFileHeader))
+
+// FileHeaderData represents the information available to the template of a file header.
+type FileHeaderData struct {
+	Time      time.Time // The time of the entry that caused the file to be opened
+	Timestamp string    // The time formatted with the default timestamp format
+	Filename  string    // The name of the file being opened
+	Command   string    // The command line of the current process
+	Pid       int       // The process id
+	Hostname  string    // The name of the host
+	Module    string    // The module of the entry that caused the file to be opened
+	Version   string    // The version set on the logger (or the version of the main module of the executable)
+}
+
+func newFileHeaderData(filename string, entry *logrus.Entry) *FileHeaderData {
+	module, _ := entry.Data[moduleFieldName].(string)
+	version, _ := entry.Data[versionFieldName].(string)
+	if version == "" {
+		version = executableVersion()
+	}
+	return &FileHeaderData{
+		Time:      entry.Time,
+		Timestamp: entry.Time.Format(defaultTimestampFormat),
+		Filename:  filename,
+		Command:   strings.Join(os.Args, " "),
+		Pid:       processID,
+		Hostname:  processHostname,
+		Module:    module,
+		Version:   version,
+	}
+}
+
+// FileHeader sets the template (text/template) used to write a header each time a file is opened.
+// FileHeaderData describes the available information. An empty template disables the header.
+// This function panics if the template is invalid.
+func FileHeader(header string) FileOption {
+	var tmpl *template.Template
+	if header != "" {
+		tmpl = template.Must(template.New("header").Parse(header))
+	}
+	return func(options *fileOptions) { options.header, options.structuredHeader = tmpl, false }
+}
+
+// FileStructuredHeader writes the header as a regular log entry with the header information as fields.
+// This allows files using a structured format (i.e. JSON) to remain valid.
+func FileStructuredHeader() FileOption {
+	return func(options *fileOptions) { options.structuredHeader = true }
+}
+
+// formatHeader returns the header of the target file, exists indicates if the file already existed.
+func (hook *fileHook) formatHeader(name string, target *fileTarget, entry *logrus.Entry, exists bool) (string, error) {
+	data := newFileHeaderData(target.path, entry)
+	if hook.structuredHeader {
+		header := entry.WithFields(logrus.Fields{
+			"command":  data.Command,
+			"pid":      data.Pid,
+			"hostname": data.Hostname,
+			"version":  data.Version,
+		})
+		header.Level, header.Message, header.Caller = logrus.InfoLevel, fileHeaderMessage, nil
+		return hook.formatEntry(name, header)
+	}
+	if hook.header == nil {
+		return "", nil
+	}
+
+	var buffer bytes.Buffer
+	if exists {
+		// Add a bit of whitespace before logging
+		buffer.WriteString("\n")
+	}
+	if err := hook.header.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return buffer.String(), nil
+}
+
+// executableVersion returns the version of the main module of the current executable.
+func executableVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return ""
+}
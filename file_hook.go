@@ -6,9 +6,10 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
-	"sync"
+	"time"
 	"unicode"
 
+	"github.com/coveooss/multilogger/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,10 +27,9 @@ func cleanupModuleName(moduleName string) string {
 
 type fileHook struct {
 	*genericHook
-	filename  string
-	isDir     bool
-	file      *os.File
-	addHeader bool
+	fileOptions
+	filename string
+	isDir    bool
 }
 
 func (hook *fileHook) clone() logrus.Hook {
@@ -37,10 +37,9 @@ func (hook *fileHook) clone() logrus.Hook {
 	// has its own attributes when the object is copied.
 	return &fileHook{
 		genericHook: hook.genericHook.clone(),
+		fileOptions: hook.fileOptions,
 		filename:    hook.filename,
 		isDir:       hook.isDir,
-		file:        hook.file,
-		addHeader:   hook.addHeader,
 	}
 }
 
@@ -54,57 +53,153 @@ func (hook *fileHook) Fire(entry *logrus.Entry) (err error) {
 			}
 		}
 
-		fileMutex.Lock()
-		defer fileMutex.Unlock()
 		targetFile := hook.filename
 		if hook.isDir {
-			moduleName := cleanupModuleName(hook.logger.GetModule())
+			// Hooks are shared by the derived loggers, so we use the module of the entry if it is available
+			module, isSet := entry.Data[moduleFieldName].(string)
+			if !isSet {
+				module = hook.getLogger().GetModule()
+			}
+			moduleName := cleanupModuleName(module)
 			targetFile = path.Join(hook.filename, strings.Replace(moduleName, ":", ".", -1)) + ".log"
-			if targetFile, err = filepath.Abs(targetFile); err != nil {
-				return err
+		}
+		if targetFile, err = filepath.Abs(targetFile); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		target := getFileTarget(targetFile)
+		target.mutex.Lock()
+		defer target.mutex.Unlock()
+		if target.mustReopen(&hook.fileOptions) {
+			if err := target.close(); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
 			}
-			if hook.file != nil && hook.file.Name() != targetFile {
-				hook.file = nil
+		}
+		currentPath, start := hook.currentPath(targetFile, entry.Time), hook.periodStart(entry.Time)
+		if currentPath != target.path && (hook.period <= 0 || start.After(target.start)) {
+			// The period has changed, we close the current file to switch to the new one. We never go back to
+			// a previous period, the late entries are written to the current file.
+			wasOpened := target.file != nil
+			if err := target.close(); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if wasOpened && hook.compression != nil {
+				target.compress(target.path, hook.compression, hook.reportError(name))
 			}
+			target.path, target.start = currentPath, start
 		}
-		if hook.file == nil {
-			logDir := path.Dir(targetFile)
-			logFileExists := false
-			if _, err := os.Stat(logDir); os.IsNotExist(err) {
-				// Log directory doesn't exist, create it
-				if err := os.MkdirAll(logDir, 0777); err != nil {
-					return fmt.Errorf("%s: %w", name, err)
-				}
-				if err := os.Chmod(logDir, 0777); err != nil {
-					return fmt.Errorf("%s: %w", name, err)
-				}
-			} else {
-				if _, err := os.Stat(targetFile); err == nil {
-					logFileExists = true
-				}
+		opened, logFileExists, err := hook.openTarget(name, target)
+		if err != nil {
+			return err
+		}
+		var header string
+		if opened {
+			if header, err = hook.formatHeader(name, target, entry, logFileExists); err != nil {
+				return err
 			}
-
-			if hook.file, err = os.OpenFile(targetFile, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0777); err != nil {
+		}
+		// The header is included in the size of the entry since it is written along with it
+		if target.shouldRotate(&hook.fileOptions, len(header)+len(output)) {
+			if err := target.rotate(&hook.fileOptions, hook.reportError(name)); err != nil {
 				return fmt.Errorf("%s: %w", name, err)
 			}
-			if err := os.Chmod(targetFile, 0777); err != nil {
-				return fmt.Errorf("%s: %w", name, err)
+			if _, logFileExists, err = hook.openTarget(name, target); err != nil {
+				return err
 			}
-			if hook.addHeader {
-				if logFileExists {
-					// Add a bit of whitespace before logging
-					if err := hook.printf(name, hook.file, "\n"); err != nil {
-						return fmt.Errorf("%s: %w", name, err)
-					}
-				}
-				if err := hook.printf(name, hook.file, "# %v\n", entry.Time.Format(defaultTimestampFormat)); err != nil {
-					return fmt.Errorf("%s: %w", name, err)
-				}
+			if header, err = hook.formatHeader(name, target, entry, logFileExists); err != nil {
+				return err
 			}
 		}
 
-		return hook.printf(name, hook.file, string(output))
+		if hook.lock {
+			// We prevent other processes from writing to the file while we are writing our entry
+			if err := lockFile(target.file); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			defer unlockFile(target.file)
+		}
+		if header != "" {
+			if err := hook.printf(name, target, header); err != nil {
+				return err
+			}
+		}
+		return hook.printf(name, target, string(output))
 	})
 }
 
-var fileMutex sync.Mutex
+// openTarget opens the target file if it is not already opened. It returns true if the file has been
+// opened and indicates if the file already existed.
+func (hook *fileHook) openTarget(name string, target *fileTarget) (opened, exists bool, err error) {
+	if target.file != nil {
+		return false, false, nil
+	}
+	if exists, err = target.open(&hook.fileOptions); err != nil {
+		return false, false, fmt.Errorf("%s: %w", name, err)
+	}
+	if err = target.prune(&hook.fileOptions, time.Now()); err != nil {
+		return false, false, fmt.Errorf("%s: %w", name, err)
+	}
+	if err = target.updateLatestLink(&hook.fileOptions); err != nil {
+		return false, false, fmt.Errorf("%s: %w", name, err)
+	}
+	return true, exists, nil
+}
+
+// reportError returns a function that reports errors occurring in background to the logger.
+func (hook *fileHook) reportError(name string) func(error) {
+	logger := hook.getLogger()
+	return func(err error) {
+		if logger != nil {
+			logger.AddError(fmt.Errorf("%s: %w", name, err))
+		}
+	}
+}
+
+// targets returns the files that have been opened by the hook.
+func (hook *fileHook) targets() (result []*fileTarget) {
+	filename, err := filepath.Abs(hook.filename)
+	if err != nil {
+		return
+	}
+	fileTargetsMutex.Lock()
+	defer fileTargetsMutex.Unlock()
+	for base, target := range fileTargets {
+		if base == filename || hook.isDir && strings.HasPrefix(base, filename+string(os.PathSeparator)) {
+			result = append(result, target)
+		}
+	}
+	return
+}
+
+// Flush waits for the completion of the background compressions.
+func (hook *fileHook) Flush() error {
+	for _, target := range hook.targets() {
+		target.compressions.wait()
+	}
+	return nil
+}
+
+// Reopen forces the files to be closed and reopened on the next write (i.e. after an external rotation).
+func (hook *fileHook) Reopen() error {
+	for _, target := range hook.targets() {
+		target.mutex.Lock()
+		target.reopen = true
+		target.mutex.Unlock()
+	}
+	return nil
+}
+
+// Close waits for the completion of the background compressions and closes the files.
+// The files are automatically reopened if the hook is used after being closed.
+func (hook *fileHook) Close() error {
+	var errs errors.Array
+	for _, target := range hook.targets() {
+		target.compressions.wait()
+		target.mutex.Lock()
+		if err := target.close(); err != nil {
+			errs = append(errs, err)
+		}
+		target.mutex.Unlock()
+	}
+	return errs.AsError()
+}
@@ -0,0 +1,682 @@
+package multilogger
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func readFile(t *testing.T, filename string) string {
+	t.Helper()
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestFileHook_SizeRotation(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("rotate")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%", FileMaxSize(40), FileMaxBackups(2))
+
+	for _, message := range []string{"first message", "second message", "third message", "fourth message"} {
+		log.Info(message)
+	}
+	assert.NoError(t, log.GetError())
+
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nfourth message\n", readFile(t, logFile))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nthird message\n", readFile(t, logFile+".1"))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nsecond message\n", readFile(t, logFile+".2"))
+	assert.NoFileExists(t, logFile+".3")
+}
+
+func TestFileHook_SizeRotationIncludesHeader(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	assert.NoError(t, os.WriteFile(logFile, []byte("previous content\n"), 0644))
+	log := getTestLogger("rotate")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%", FileMaxSize(40), FileMaxBackups(1))
+
+	// The existing file has room for the message, but not for the header written along with it
+	log.Info("short message")
+	assert.NoError(t, log.Close())
+
+	assert.Equal(t, "previous content\n", readFile(t, logFile+".1"))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nshort message\n", readFile(t, logFile))
+}
+
+func TestFileHook_SizeRotationWithoutBackup(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("rotate")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%", FileMaxSize(40), FileMaxBackups(0))
+
+	log.Info("first message")
+	log.Info("second message")
+	assert.NoError(t, log.GetError())
+
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nsecond message\n", readFile(t, logFile))
+	assert.NoFileExists(t, logFile+".1")
+}
+
+func TestFileHook_SizeRotationSharedByClones(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("rotate")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%module% %message%", FileMaxSize(50), FileBackupName(func(filename string, index int) string {
+		return strings.TrimSuffix(filename, ".log") + ".old" + strings.Repeat("+", index) + ".log"
+	}))
+	child := log.Child("child")
+
+	log.Info("first message")
+	child.Info("second message")
+	log.Info("third message")
+	assert.NoError(t, log.GetError())
+	assert.NoError(t, child.GetError())
+
+	backup := strings.TrimSuffix(logFile, ".log") + ".old"
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nrotate third message\n", readFile(t, logFile))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nrotate:child second message\n", readFile(t, backup+"+.log"))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nrotate first message\n", readFile(t, backup+"++.log"))
+}
+
+func TestFileHook_SizeRotationFolder(t *testing.T) {
+	logDir := t.TempDir()
+	log := getTestLogger("main")
+	log.AddFile(logDir, true, logrus.InfoLevel, "%message%", FileMaxSize(40))
+	child := log.Child("child")
+
+	log.Info("first message")
+	child.Info("first child message")
+	log.Info("second message")
+	assert.NoError(t, log.GetError())
+
+	mainFile, childFile := filepath.Join(logDir, "main.log"), filepath.Join(logDir, "main.child.log")
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nsecond message\n", readFile(t, mainFile))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nfirst message\n", readFile(t, mainFile+".1"))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nfirst child message\n", readFile(t, childFile))
+	assert.NoFileExists(t, childFile+".1")
+}
+
+func TestFileHook_TimeRotation(t *testing.T) {
+	logDir := t.TempDir()
+	logFile := filepath.Join(logDir, "debug.log")
+	log := getTestLogger("rotate")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%", FileRotateEvery(24*time.Hour))
+
+	log.Info("first day")
+	log.WithTime(baseTime.Add(time.Hour)).Info("still first day")
+	log.WithTime(baseTime.Add(24 * time.Hour)).Info("second day")
+	assert.NoError(t, log.GetError())
+
+	assert.NoFileExists(t, logFile)
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nfirst day\nstill first day\n", readFile(t, filepath.Join(logDir, "debug-2018-06-24.log")))
+	assert.Equal(t, "# 2018/06/25 12:34:56.789\nsecond day\n", readFile(t, filepath.Join(logDir, "debug-2018-06-25.log")))
+}
+
+func TestFileHook_TimeRotationLateEntry(t *testing.T) {
+	logDir := t.TempDir()
+	logFile := filepath.Join(logDir, "debug.log")
+	log := getTestLogger("rotate")
+	hook := NewFileHook(logFile, false, logrus.InfoLevel, "%message%", FileRotateEvery(24*time.Hour), FileCompress(GzipCompression))
+	log.AddHooks(hook)
+
+	log.Info("first day")
+	log.WithTime(baseTime.Add(24 * time.Hour)).Info("second day")
+	assert.NoError(t, log.Flush())
+	// An entry of the previous period (i.e. logged concurrently at midnight) is written to the current file
+	log.Info("late first day")
+	assert.NoError(t, log.Close())
+	assert.NoError(t, log.GetError())
+
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nfirst day\n", readGzip(t, filepath.Join(logDir, "debug-2018-06-24.log.gz")))
+	assert.Equal(t, "# 2018/06/25 12:34:56.789\nsecond day\nlate first day\n", readFile(t, filepath.Join(logDir, "debug-2018-06-25.log")))
+	assert.NoFileExists(t, filepath.Join(logDir, "debug-2018-06-24.log"))
+}
+
+func TestFileHook_TimeRotationSeveralDays(t *testing.T) {
+	logDir := t.TempDir()
+	logFile := filepath.Join(logDir, "debug.log")
+	log := getTestLogger("rotate")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%", FileRotateEvery(7*24*time.Hour))
+
+	// The weekly periods start on Mondays (June 24, 2018 is a Sunday)
+	log.WithTime(baseTime.Add(-48 * time.Hour)).Info("friday")
+	log.Info("sunday")
+	log.WithTime(baseTime.Add(24 * time.Hour)).Info("monday")
+	log.WithTime(baseTime.Add(7 * 24 * time.Hour)).Info("next sunday")
+	assert.NoError(t, log.GetError())
+
+	assert.Equal(t, "# 2018/06/22 12:34:56.789\nfriday\nsunday\n", readFile(t, filepath.Join(logDir, "debug-2018-06-18.log")))
+	assert.Equal(t, "# 2018/06/25 12:34:56.789\nmonday\nnext sunday\n", readFile(t, filepath.Join(logDir, "debug-2018-06-25.log")))
+}
+
+func TestFileHook_TimeRotationRetention(t *testing.T) {
+	logDir := t.TempDir()
+	logFile := filepath.Join(logDir, "debug.log")
+	old := time.Now().Add(-48 * time.Hour)
+	for i, name := range []string{"debug-2018-06-20-10.log", "debug-2018-06-21-10.log", "debug-2018-06-22-10.log.1", "debug-2018-06-23-10.log", "debug-notadate.log", "other.log"} {
+		filename := filepath.Join(logDir, name)
+		assert.NoError(t, os.WriteFile(filename, nil, 0644))
+		assert.NoError(t, os.Chtimes(filename, old, old.Add(time.Duration(i)*time.Minute)))
+	}
+
+	log := getTestLogger("rotate")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%", FileRotateEvery(time.Hour), FileMaxBackups(2))
+	log.Info("Hello")
+	assert.NoError(t, log.GetError())
+
+	var files []string
+	entries, _ := os.ReadDir(logDir)
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	assert.Equal(t, []string{"debug-2018-06-22-10.log.1", "debug-2018-06-23-10.log", "debug-2018-06-24-12.log", "debug-notadate.log", "other.log"}, files)
+}
+
+func TestFileHook_MaxAge(t *testing.T) {
+	logDir := t.TempDir()
+	log := getTestLogger("main")
+	log.AddFile(logDir, true, logrus.InfoLevel, "%message%", FileMaxAge(24*time.Hour))
+
+	oldFile, recentFile := filepath.Join(logDir, "main.log.1"), filepath.Join(logDir, "main.log.2")
+	otherModule := filepath.Join(logDir, "main.child.log.1")
+	for _, filename := range []string{oldFile, recentFile, otherModule} {
+		assert.NoError(t, os.WriteFile(filename, nil, 0644))
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(oldFile, old, old))
+	assert.NoError(t, os.Chtimes(otherModule, old, old))
+
+	log.Info("Hello")
+	assert.NoError(t, log.GetError())
+	assert.NoFileExists(t, oldFile)
+	assert.FileExists(t, recentFile)
+	assert.FileExists(t, otherModule)
+}
+
+func readGzip(t *testing.T, filename string) string {
+	t.Helper()
+	file, err := os.Open(filename)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	content, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestFileHook_Compression(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("compress")
+	hook := NewFileHook(logFile, false, logrus.InfoLevel, "%message%", FileMaxSize(40), FileMaxBackups(2), FileCompress(GzipCompression))
+	log.AddHooks(hook)
+
+	for _, message := range []string{"first message", "second message", "third message", "fourth message"} {
+		log.Info(message)
+	}
+	assert.NoError(t, hook.GetInnerHook().(io.Closer).Close())
+	assert.NoError(t, log.GetError())
+
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nfourth message\n", readFile(t, logFile))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nthird message\n", readGzip(t, logFile+".1.gz"))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nsecond message\n", readGzip(t, logFile+".2.gz"))
+	assert.NoFileExists(t, logFile+".1")
+	assert.NoFileExists(t, logFile+".2")
+	assert.NoFileExists(t, logFile+".3.gz")
+}
+
+func TestFileHook_CompressionDoesNotBlock(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("compress")
+	release := make(chan struct{})
+	blocking := Compression{".gz", func(w io.Writer) (io.WriteCloser, error) {
+		<-release
+		return gzip.NewWriter(w), nil
+	}}
+	hook := NewFileHook(logFile, false, logrus.InfoLevel, "%message%", FileMaxSize(40), FileMaxBackups(2), FileCompress(blocking))
+	log.AddHooks(hook)
+
+	// The rotations must not wait for the compression of the previous backups
+	for _, message := range []string{"first message", "second message", "third message", "fourth message"} {
+		log.Info(message)
+	}
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nfourth message\n", readFile(t, logFile))
+	close(release)
+	assert.NoError(t, hook.GetInnerHook().(io.Closer).Close())
+	assert.NoError(t, log.GetError())
+
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nthird message\n", readGzip(t, logFile+".1.gz"))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nsecond message\n", readGzip(t, logFile+".2.gz"))
+	assert.NoFileExists(t, logFile+".1")
+	assert.NoFileExists(t, logFile+".2")
+	assert.NoFileExists(t, logFile+".3.gz")
+	staging, _ := filepath.Glob(logFile + ".rotating-*")
+	assert.Empty(t, staging)
+}
+
+func TestFileHook_CompressionTimeRotation(t *testing.T) {
+	logDir := t.TempDir()
+	log := getTestLogger("compress")
+	hook := NewFileHook(logDir, true, logrus.InfoLevel, "%message%", FileRotateEvery(time.Hour), FileCompress(GzipCompression))
+	log.AddHooks(hook)
+
+	log.Info("first hour")
+	log.WithTime(baseTime.Add(time.Hour)).Info("second hour")
+	assert.NoError(t, hook.GetInnerHook().(interface{ Flush() error }).Flush())
+	assert.NoError(t, log.GetError())
+
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nfirst hour\n", readGzip(t, filepath.Join(logDir, "compress-2018-06-24-12.log.gz")))
+	assert.Equal(t, "# 2018/06/24 13:34:56.789\nsecond hour\n", readFile(t, filepath.Join(logDir, "compress-2018-06-24-13.log")))
+	assert.NoFileExists(t, filepath.Join(logDir, "compress-2018-06-24-12.log"))
+}
+
+func TestFileHook_CompressionExistingArchive(t *testing.T) {
+	logDir := t.TempDir()
+	logFile := filepath.Join(logDir, "debug.log")
+	archive := filepath.Join(logDir, "debug-2018-06-24.log.gz")
+
+	// The archive has been created by a previous run
+	file, err := os.Create(archive)
+	assert.NoError(t, err)
+	writer := gzip.NewWriter(file)
+	writer.Write([]byte("previous run\n"))
+	assert.NoError(t, writer.Close())
+	assert.NoError(t, file.Close())
+
+	log := getTestLogger("compress")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%", FileRotateEvery(24*time.Hour), FileCompress(GzipCompression))
+	log.Info("first day")
+	log.WithTime(baseTime.Add(24 * time.Hour)).Info("second day")
+	assert.NoError(t, log.Close())
+	assert.NoError(t, log.GetError())
+
+	assert.Equal(t, "previous run\n# 2018/06/24 12:34:56.789\nfirst day\n", readGzip(t, archive))
+	assert.NoFileExists(t, filepath.Join(logDir, "debug-2018-06-24.log"))
+}
+
+func TestFileHook_CompressionError(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("compress")
+	failure := Compression{".broken", func(io.Writer) (io.WriteCloser, error) { return nil, fmt.Errorf("compression failure") }}
+	hook := NewFileHook(logFile, false, logrus.InfoLevel, "%message%", FileMaxSize(40), FileCompress(failure))
+	log.AddHooks(hook)
+
+	log.Info("first message")
+	log.Info("second message")
+	assert.NoError(t, hook.GetInnerHook().(io.Closer).Close())
+	assert.EqualError(t, log.GetError(), fmt.Sprintf("FileHook %s: compression failure", logFile))
+	assert.FileExists(t, logFile+".1")
+	assert.NoFileExists(t, logFile+".1.broken")
+}
+
+func TestFileHook_Reopen(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("reopen")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%")
+
+	log.Info("before rotation")
+	assert.NoError(t, os.Rename(logFile, logFile+".old"))
+	log.Info("still in the old file")
+	assert.NoError(t, log.Reopen())
+	log.Child("child").Info("after rotation")
+	assert.NoError(t, log.GetError())
+
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nbefore rotation\nstill in the old file\n", readFile(t, logFile+".old"))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nafter rotation\n", readFile(t, logFile))
+}
+
+func TestFileHook_ReopenWhileClosed(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("reopen")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%")
+
+	log.Info("first message")
+	assert.NoError(t, log.Close())
+	assert.NoError(t, log.Reopen())
+	log.Info("second message")
+	log.Info("third message")
+	assert.NoError(t, log.GetError())
+
+	// The header must only be written once when the file is reopened
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nfirst message\n\n# 2018/06/24 12:34:56.789\nsecond message\nthird message\n", readFile(t, logFile))
+}
+
+func TestFileHook_Watch(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("watch")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%", FileWatch(0))
+
+	log.Info("before rotation")
+	assert.NoError(t, os.Rename(logFile, logFile+".old"))
+	log.Info("after rotation")
+	assert.NoError(t, os.Remove(logFile))
+	log.Info("after deletion")
+	assert.NoError(t, log.GetError())
+
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nbefore rotation\n", readFile(t, logFile+".old"))
+	assert.Equal(t, "# 2018/06/24 12:34:56.789\nafter deletion\n", readFile(t, logFile))
+}
+
+func TestLogger_ReopenOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Signals are not supported on Windows")
+	}
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("signal")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%")
+	stop := log.ReopenOnSignal(syscall.SIGHUP)
+	defer stop()
+
+	log.Info("before rotation")
+	assert.NoError(t, os.Rename(logFile, logFile+".old"))
+	process, _ := os.FindProcess(os.Getpid())
+	assert.NoError(t, process.Signal(syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		log.Info("after rotation")
+		_, err := os.Stat(logFile)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, log.GetError())
+	assert.True(t, strings.HasPrefix(readFile(t, logFile+".old"), "# 2018/06/24 12:34:56.789\nbefore rotation\n"))
+}
+
+func TestFileHook_Permissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Permissions are not supported on Windows")
+	}
+	tests := []struct {
+		name              string
+		options           []interface{}
+		filePerm, dirPerm os.FileMode
+		enforced          bool
+	}{
+		{"Default", nil, 0644, 0755, false},
+		{"Custom", []interface{}{FilePerm(0600), FileDirPerm(0700), FileChmod(true)}, 0600, 0700, true},
+		{"Legacy", []interface{}{FileLegacyPermissions()}, 0777, 0777, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logDir := filepath.Join(t.TempDir(), "logs")
+			logFile := filepath.Join(logDir, "debug.log")
+			log := getTestLogger("permissions")
+			log.AddFile(logFile, false, logrus.InfoLevel, append(tt.options, "%message%")...)
+			log.Info("Hello")
+			assert.NoError(t, log.GetError())
+
+			checkPerm := func(filename string, expected os.FileMode) {
+				info, err := os.Stat(filename)
+				if !assert.NoError(t, err) {
+					return
+				}
+				if tt.enforced {
+					assert.Equal(t, expected, info.Mode().Perm())
+				} else {
+					// The umask may have removed some permissions
+					assert.Zero(t, info.Mode().Perm()&^expected)
+				}
+			}
+			checkPerm(logFile, tt.filePerm)
+			checkPerm(logDir, tt.dirPerm)
+		})
+	}
+}
+
+func TestFileHook_NoCreateDirs(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "missing", "debug.log")
+	log := getTestLogger("permissions")
+	log.AddFile(logFile, false, logrus.InfoLevel, FileCreateDirs(false))
+	log.Info("Hello")
+	assert.ErrorIs(t, log.GetError(), os.ErrNotExist)
+	assert.NoDirExists(t, filepath.Dir(logFile))
+}
+
+func TestFileHook_Close(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("close")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%")
+
+	target := func() *fileTarget {
+		fileTargetsMutex.Lock()
+		defer fileTargetsMutex.Unlock()
+		return fileTargets[logFile]
+	}
+	log.Info("Before close")
+	assert.NotNil(t, target().file)
+	assert.NoError(t, log.Close())
+	assert.Nil(t, target().file)
+
+	// The file is reopened if the logger is used after being closed
+	log.Info("After close")
+	assert.NoError(t, log.GetError())
+	assert.NotNil(t, target().file)
+	log.RemoveHook(logFile)
+	assert.Nil(t, target().file)
+	assert.NoError(t, log.GetError())
+}
+
+func TestFileHook_LockAcrossProcesses(t *testing.T) {
+	if logFile := os.Getenv("MULTILOGGER_TEST_LOCK_FILE"); logFile != "" {
+		// We are running as a child process
+		log := getTestLogger("lock")
+		log.AddFile(logFile, false, logrus.InfoLevel, "%message%", FileLock(true))
+		for i := 0; i < 100; i++ {
+			log.Infof("%d %s", os.Getpid(), strings.Repeat("x", 1000))
+		}
+		if err := log.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	var processes []*exec.Cmd
+	for i := 0; i < 4; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFileHook_LockAcrossProcesses$")
+		cmd.Env = append(os.Environ(), "MULTILOGGER_TEST_LOCK_FILE="+logFile)
+		assert.NoError(t, cmd.Start())
+		processes = append(processes, cmd)
+	}
+	for _, cmd := range processes {
+		assert.NoError(t, cmd.Wait())
+	}
+
+	var count int
+	for _, line := range strings.Split(readFile(t, logFile), "\n") {
+		if line == "" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		assert.Regexp(t, `^\d+ x{1000}$`, line)
+		count++
+	}
+	assert.Equal(t, 400, count)
+}
+
+func TestFileHook_ConcurrentTargets(t *testing.T) {
+	logDir := t.TempDir()
+	log := getTestLogger("concurrent")
+	log.AddFile(logDir, true, logrus.InfoLevel, "%message%")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		child := log.Child(fmt.Sprint(i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				child.Info("message")
+			}
+		}()
+	}
+	wg.Wait()
+	assert.NoError(t, log.Close())
+
+	for i := 0; i < 4; i++ {
+		content := readFile(t, filepath.Join(logDir, fmt.Sprintf("concurrent.%d.log", i)))
+		assert.Equal(t, 51, strings.Count(content, "\n"))
+	}
+}
+
+func TestFileHook_Header(t *testing.T) {
+	hostname, _ := os.Hostname()
+	tests := []struct {
+		name    string
+		options []interface{}
+		want    string
+	}{
+		{"Default", nil, "# 2018/06/24 12:34:56.789\nHello\n"},
+		{"No header", []interface{}{FileHeader("")}, "Hello\n"},
+		{"Custom", []interface{}{FileHeader("## {{ .Module }} {{ .Pid }} {{ .Hostname }} {{ .Time.Year }}\n")}, fmt.Sprintf("## header %d %s 2018\nHello\n", os.Getpid(), hostname)},
+		{"Command", []interface{}{FileHeader("{{ .Command }}\n")}, strings.Join(os.Args, " ") + "\nHello\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logFile := filepath.Join(t.TempDir(), "debug.log")
+			log := getTestLogger("header")
+			log.AddFile(logFile, false, logrus.InfoLevel, append(tt.options, "%message%")...)
+			log.Info("Hello")
+			assert.NoError(t, log.GetError())
+			assert.Equal(t, tt.want, readFile(t, logFile))
+
+			// A blank line is added before the header when the file already exists
+			assert.NoError(t, log.Close())
+			log.Info("Hello")
+			want := tt.want + tt.want
+			if strings.TrimSpace(tt.want) != "Hello" {
+				want = tt.want + "\n" + tt.want
+			}
+			assert.Equal(t, want, readFile(t, logFile))
+		})
+	}
+}
+
+func TestFileHook_InvalidHeader(t *testing.T) {
+	assert.Panics(t, func() { FileHeader("{{ .Invalid") })
+
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("header")
+	log.AddFile(logFile, false, logrus.InfoLevel, FileHeader("{{ .Unknown }}"))
+	log.Info("Hello")
+	assert.ErrorContains(t, log.GetError(), fmt.Sprintf("FileHook %s: template: header:1:3: executing", logFile))
+}
+
+func TestFileHook_StructuredHeader(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("header")
+	log.AddFile(logFile, false, logrus.InfoLevel, FileStructuredHeader(), new(logrus.JSONFormatter))
+	log.Warning("Hello")
+	assert.NoError(t, log.GetError())
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, logFile)), "\n")
+	if assert.Len(t, lines, 2) {
+		var header map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+		assert.Equal(t, "Log file opened", header["msg"])
+		assert.Equal(t, "info", header["level"])
+		assert.Equal(t, "header", header[moduleFieldName])
+		assert.Equal(t, float64(os.Getpid()), header["pid"])
+		assert.Contains(t, header, "hostname")
+		assert.Contains(t, header, "command")
+		assert.Contains(t, header, "version")
+		assert.Equal(t, `{"level":"warning","module-field":"header","msg":"Hello","time":"2018-06-24T12:34:56Z"}`, lines[1])
+	}
+}
+
+func TestFileHook_RunNameAndLatestLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symbolic links require privileges on Windows")
+	}
+	logDir := t.TempDir()
+	log := getTestLogger("main")
+	log.AddFile(logDir, true, logrus.InfoLevel, "%message%", FileHeader(""), FileRunName("%name%-%pid%%ext%"), FileLatestLink("%name%.latest%ext%"), FileRotateEvery(time.Hour))
+
+	log.Info("first hour")
+	log.Child("child").Info("child message")
+	log.WithTime(baseTime.Add(time.Hour)).Info("second hour")
+	assert.NoError(t, log.GetError())
+
+	pid := os.Getpid()
+	assert.Equal(t, "first hour\n", readFile(t, filepath.Join(logDir, fmt.Sprintf("main-%d-2018-06-24-12.log", pid))))
+	assert.Equal(t, "second hour\n", readFile(t, filepath.Join(logDir, fmt.Sprintf("main-%d-2018-06-24-13.log", pid))))
+	assert.Equal(t, "child message\n", readFile(t, filepath.Join(logDir, fmt.Sprintf("main.child-%d-2018-06-24-12.log", pid))))
+
+	link, err := os.Readlink(filepath.Join(logDir, "main.latest.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("main-%d-2018-06-24-13.log", pid), link)
+	assert.Equal(t, "second hour\n", readFile(t, filepath.Join(logDir, "main.latest.log")))
+	link, err = os.Readlink(filepath.Join(logDir, "main.child.latest.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("main.child-%d-2018-06-24-12.log", pid), link)
+}
+
+func TestFileHook_RunNameRetention(t *testing.T) {
+	logDir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	// Files created by previous runs (with different process ids) and unrelated files
+	for i, name := range []string{"debug-1-2018-06-20-10.log", "debug-2-2018-06-21-10.log.1", "debug-3.log", "debug-4-2018-06-23-10.log", "debug-x.log", "debug.other-5.log"} {
+		filename := filepath.Join(logDir, name)
+		assert.NoError(t, os.WriteFile(filename, nil, 0644))
+		assert.NoError(t, os.Chtimes(filename, old, old.Add(time.Duration(i)*time.Minute)))
+	}
+
+	log := getTestLogger("rotate")
+	log.AddFile(filepath.Join(logDir, "debug.log"), false, logrus.InfoLevel, "%message%", FileRunName("%name%-%pid%%ext%"), FileRotateEvery(time.Hour), FileMaxBackups(2))
+	log.Info("Hello")
+	assert.NoError(t, log.GetError())
+
+	var files []string
+	entries, _ := os.ReadDir(logDir)
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	current := fmt.Sprintf("debug-%d-2018-06-24-12.log", os.Getpid())
+	assert.ElementsMatch(t, []string{"debug-3.log", "debug-4-2018-06-23-10.log", current, "debug-x.log", "debug.other-5.log"}, files)
+}
+
+func TestFileHook_RunNameRetentionWithoutTimeRotation(t *testing.T) {
+	logDir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	current := fmt.Sprintf("debug-%d.log", os.Getpid())
+	// Files created by previous runs and a backup of the current run
+	for i, name := range []string{"debug-1.log", "debug-2.log", "debug-3.log.1", "debug-4.log", "debug-5.log", current + ".1"} {
+		filename := filepath.Join(logDir, name)
+		assert.NoError(t, os.WriteFile(filename, nil, 0644))
+		assert.NoError(t, os.Chtimes(filename, old, old.Add(time.Duration(i)*time.Minute)))
+	}
+
+	log := getTestLogger("rotate")
+	log.AddFile(filepath.Join(logDir, "debug.log"), false, logrus.InfoLevel, "%message%", FileRunName("%name%-%pid%%ext%"), FileMaxBackups(2))
+	log.Info("Hello")
+	assert.NoError(t, log.GetError())
+
+	var files []string
+	entries, _ := os.ReadDir(logDir)
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{"debug-4.log", "debug-5.log", current, current + ".1"}, files)
+}
+
+func TestFileHook_RunNameTime(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	log := getTestLogger("run")
+	log.AddFile(logFile, false, logrus.InfoLevel, "%message%", FileRunName("run-%time%%ext%"))
+	log.Info("Hello")
+	assert.NoError(t, log.GetError())
+	assert.NoFileExists(t, logFile)
+	assert.FileExists(t, filepath.Join(filepath.Dir(logFile), "run-"+processStart.Format("20060102-150405")+".log"))
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package multilogger
+
+import "os"
+
+// Advisory locks are not supported on this platform, file writes are only serialized within the process.
+func lockFile(*os.File) error   { return nil }
+func unlockFile(*os.File) error { return nil }
@@ -0,0 +1,59 @@
+package multilogger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const runTimeLayout = "20060102-150405"
+
+// processStart is used to identify the current run in the log file names.
+var processStart = time.Now()
+
+// expandFileName replaces the %name% and %ext% placeholders by the corresponding parts of the base name
+// and returns the resulting path in the same folder as base.
+func expandFileName(base, pattern string, placeholders ...string) string {
+	folder, name := filepath.Split(base)
+	ext := filepath.Ext(name)
+	placeholders = append(placeholders, "%name%", strings.TrimSuffix(name, ext), "%ext%", ext)
+	return filepath.Join(folder, strings.NewReplacer(placeholders...).Replace(pattern))
+}
+
+// runPath returns the name of the file for the current run.
+func (options *fileOptions) runPath(base string) string {
+	if options.runName == "" {
+		return base
+	}
+	return expandFileName(base, options.runName,
+		"%time%", processStart.Format(runTimeLayout),
+		"%pid%", strconv.Itoa(processID),
+	)
+}
+
+// updateLatestLink makes the latest link point to the current file of the target.
+func (target *fileTarget) updateLatestLink(options *fileOptions) error {
+	if options.latestLink == "" {
+		return nil
+	}
+	link := expandFileName(target.base, options.latestLink)
+	if link == target.path {
+		return nil
+	}
+	destination, err := filepath.Rel(filepath.Dir(link), target.path)
+	if err != nil {
+		destination = target.path
+	}
+	// We create the link under a temporary name and rename it to atomically replace the previous link
+	temp := fmt.Sprintf("%s.%d.tmp", link, processID)
+	if err := removeIfExists(temp); err != nil {
+		return err
+	}
+	if err := os.Symlink(destination, temp); err != nil {
+		return err
+	}
+	return os.Rename(temp, link)
+}
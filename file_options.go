@@ -0,0 +1,177 @@
+package multilogger
+
+import (
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultMaxBackups = 3
+	defaultFilePerm   = 0644
+	defaultDirPerm    = 0755
+)
+
+// FileOption represents an option that can be supplied to NewFileHook (or Logger.AddFile)
+// along with the format arguments.
+type FileOption func(*fileOptions)
+
+type fileOptions struct {
+	maxSize          int64
+	maxBackups       int
+	backupName       func(filename string, index int) string
+	period           time.Duration
+	timeLayout       string
+	maxAge           time.Duration
+	compression      *Compression
+	watch            bool
+	watchInterval    time.Duration
+	perm             os.FileMode
+	dirPerm          os.FileMode
+	chmod            bool
+	createDirs       bool
+	lock             bool
+	header           *template.Template
+	structuredHeader bool
+	runName          string
+	latestLink       string
+}
+
+func defaultFileOptions() fileOptions {
+	return fileOptions{
+		maxBackups: defaultMaxBackups,
+		backupName: DefaultBackupName,
+		perm:       defaultFilePerm,
+		dirPerm:    defaultDirPerm,
+		createDirs: true,
+		header:     defaultFileHeader,
+	}
+}
+
+// FileMaxSize enables the size based rotation of the log file. The file is rotated before
+// writing an entry that would make it larger than size bytes (0 disables the rotation).
+func FileMaxSize(size int64) FileOption {
+	return func(options *fileOptions) { options.maxSize = size }
+}
+
+// FileMaxBackups sets the number of rotated files that are kept (default is 3).
+// If count is 0, the file is simply truncated when it is rotated by size.
+// If time rotation is enabled, files from previous periods are also counted as backups. If the files are named
+// per run (see FileRunName), files from previous runs are also counted as backups.
+func FileMaxBackups(count int) FileOption {
+	return func(options *fileOptions) { options.maxBackups = count }
+}
+
+// FileBackupName allows user to define the name given to a backup file when a log file is rotated.
+// The most recent backup has index 1 (default is DefaultBackupName).
+func FileBackupName(name func(filename string, index int) string) FileOption {
+	return func(options *fileOptions) { options.backupName = name }
+}
+
+// FileRotateEvery enables the time based rotation of the log file. The time of the period is inserted in the
+// name of the file (i.e. debug-2026-10-16.log for a daily rotation). Periods of a day or more are rounded down
+// to whole days and aligned on calendar days. Periods of several days are aligned on multiples of the period
+// counted from Monday, January 5, 1970 (i.e. a weekly rotation starts on Mondays). Entries logged with a time
+// that belongs to a previous period (i.e. logged concurrently at midnight) are written to the current file.
+func FileRotateEvery(period time.Duration) FileOption {
+	return func(options *fileOptions) { options.period = period }
+}
+
+// FileTimeLayout allows user to define the layout used to insert the period in the file name when time
+// rotation is enabled. By default, the layout is determined by the rotation period.
+func FileTimeLayout(layout string) FileOption {
+	return func(options *fileOptions) { options.timeLayout = layout }
+}
+
+// FileMaxAge enables the deletion of rotated files that have not been modified since the specified age.
+func FileMaxAge(age time.Duration) FileOption {
+	return func(options *fileOptions) { options.maxAge = age }
+}
+
+// FileCompress enables the compression of rotated files (i.e. GzipCompression). The compression is done
+// in background and the errors are reported to the logger.
+func FileCompress(compression Compression) FileOption {
+	return func(options *fileOptions) { options.compression = &compression }
+}
+
+// FileWatch enables the detection of log files that have been moved or deleted by an external
+// process (i.e. logrotate). The file is checked at most once per interval (0 means on every write)
+// and it is reopened if it is no longer the file that is on the disk.
+func FileWatch(interval time.Duration) FileOption {
+	return func(options *fileOptions) { options.watch, options.watchInterval = true, interval }
+}
+
+// FilePerm sets the permissions used to create the log files (default is 0644, before umask).
+func FilePerm(perm os.FileMode) FileOption {
+	return func(options *fileOptions) { options.perm = perm }
+}
+
+// FileDirPerm sets the permissions used to create the missing log directories (default is 0755, before umask).
+func FileDirPerm(perm os.FileMode) FileOption {
+	return func(options *fileOptions) { options.dirPerm = perm }
+}
+
+// FileChmod forces the permissions of the log files (and of the created directories) regardless of the umask.
+// Note that the log files are chmoded each time they are opened, even if they already existed.
+func FileChmod(chmod bool) FileOption {
+	return func(options *fileOptions) { options.chmod = chmod }
+}
+
+// FileCreateDirs determines if missing log directories are created (default is true).
+func FileCreateDirs(create bool) FileOption {
+	return func(options *fileOptions) { options.createDirs = create }
+}
+
+// FileLegacyPermissions restores the behaviour of previous versions where the log files and directories
+// were created with 0777 permissions, enforced with chmod.
+func FileLegacyPermissions() FileOption {
+	return func(options *fileOptions) {
+		options.perm, options.dirPerm, options.chmod, options.createDirs = 0777, 0777, true, true
+	}
+}
+
+// FileLock enables the use of an advisory lock (flock) on the log file while writing an entry. This allows
+// several processes to append to the same file without interleaving partial lines. Note that the rotation
+// is not coordinated between processes. This option has no effect on systems that do not support flock.
+func FileLock(lock bool) FileOption {
+	return func(options *fileOptions) { options.lock = lock }
+}
+
+// FileRunName allows each run of the process to write to a distinct file. The pattern is used to build the
+// name of the file (in the same folder) using the following placeholders:
+//
+//	%name%: the name of the file without extension (the module name in folder mode)
+//	%ext%: the extension of the file (including the dot)
+//	%time%: the start time of the process (i.e. 20261016-123456)
+//	%pid%: the process id
+//
+// i.e. "%name%-%time%-%pid%%ext%" writes debug.log as debug-20261016-123456-1234.log.
+// The files written by the previous runs are subject to the same retention policy as the rotated files.
+func FileRunName(pattern string) FileOption {
+	return func(options *fileOptions) { options.runName = pattern }
+}
+
+// FileLatestLink creates a symbolic link pointing to the file currently written. The pattern is used to build
+// the name of the link (in the same folder) and supports the %name% and %ext% placeholders (i.e. "%name%.latest%ext%").
+func FileLatestLink(pattern string) FileOption {
+	return func(options *fileOptions) { options.latestLink = pattern }
+}
+
+// DefaultBackupName returns the backup name used by default when a log file is rotated (i.e. debug.log.1).
+func DefaultBackupName(filename string, index int) string {
+	return fmt.Sprintf("%s.%d", filename, index)
+}
+
+// extractFileOptions separates file options from the format arguments.
+func extractFileOptions(args []interface{}) (options fileOptions, format []interface{}) {
+	options = defaultFileOptions()
+	for _, arg := range args {
+		if option, ok := arg.(FileOption); ok {
+			option(&options)
+			continue
+		}
+		format = append(format, arg)
+	}
+	return
+}
@@ -0,0 +1,141 @@
+package multilogger
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// getTimeLayout returns the layout used to insert the current period in the file name.
+func (options *fileOptions) getTimeLayout() string {
+	switch {
+	case options.timeLayout != "":
+		return options.timeLayout
+	case options.period >= day:
+		return "2006-01-02"
+	case options.period >= time.Hour:
+		return "2006-01-02-15"
+	default:
+		return "2006-01-02-15-04"
+	}
+}
+
+// periodStart returns the beginning of the period containing t.
+func (options *fileOptions) periodStart(t time.Time) time.Time {
+	if options.period < day {
+		return t.Truncate(options.period)
+	}
+	year, month, dayOfMonth := t.Date()
+	start := time.Date(year, month, dayOfMonth, 0, 0, 0, 0, t.Location())
+	if days := int(options.period / day); days > 1 {
+		// Periods of several days are aligned on multiples of the period counted from a Monday
+		reference := time.Date(1970, 1, 5, 0, 0, 0, 0, t.Location())
+		elapsed := int(math.Round(start.Sub(reference).Hours() / 24))
+		start = start.AddDate(0, 0, -((elapsed%days)+days)%days)
+	}
+	return start
+}
+
+// currentPath returns the name of the file that should be written for the logical name base at time t.
+func (options *fileOptions) currentPath(base string, t time.Time) string {
+	base = options.runPath(base)
+	if options.period <= 0 {
+		return base
+	}
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "-" + options.periodStart(t).Format(options.getTimeLayout()) + ext
+}
+
+// rotatedFileMatcher returns a function that checks if the file name (without directory) is a file rotated
+// from the logical name base. If the files are named per run (see FileRunName), the run placeholders match
+// any value, so the files of the previous runs are also considered as rotated files.
+func (options *fileOptions) rotatedFileMatcher(base string) func(name string) bool {
+	baseName := regexp.QuoteMeta(filepath.Base(options.runPath(base)))
+	if options.runName != "" {
+		const timeMarker, pidMarker = "\x00time\x00", "\x00pid\x00"
+		baseName = regexp.QuoteMeta(filepath.Base(expandFileName(base, options.runName, "%time%", timeMarker, "%pid%", pidMarker)))
+		baseName = strings.NewReplacer(timeMarker, `\d{8}-\d{6}`, pidMarker, `\d+`).Replace(baseName)
+	}
+	ext := regexp.QuoteMeta(filepath.Ext(options.runPath(base)))
+	stem := strings.TrimSuffix(baseName, ext)
+
+	// Backups created by a size rotation (i.e. debug.log.1) and, if named per run, files of the other runs
+	backup := regexp.MustCompile(`^` + baseName + `\.\d`)
+	run := regexp.MustCompile(`^` + baseName + `$`)
+	layout := options.getTimeLayout()
+	period := regexp.MustCompile(fmt.Sprintf(`^%s-(.{%d})%s`, stem, len(time.Time{}.Format(layout)), ext))
+
+	return func(name string) bool {
+		if backup.MatchString(name) || options.runName != "" && run.MatchString(name) {
+			return true
+		}
+		if options.period <= 0 {
+			return false
+		}
+		matches := period.FindStringSubmatch(name)
+		if matches == nil {
+			return false
+		}
+		_, err := time.Parse(layout, matches[1])
+		return err == nil
+	}
+}
+
+// prune deletes the rotated files that exceed the retention policy.
+func (target *fileTarget) prune(options *fileOptions, now time.Time) error {
+	if options.period <= 0 && options.maxAge <= 0 && options.runName == "" {
+		// Backups created by size rotation are already limited by the rotation itself
+		return nil
+	}
+
+	folder := filepath.Dir(options.runPath(target.base))
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return err
+	}
+	isRotatedFile := options.rotatedFileMatcher(target.base)
+
+	var rotated []os.FileInfo
+	for _, entry := range entries {
+		filename := filepath.Join(folder, entry.Name())
+		if entry.IsDir() || filename == target.path || !isRotatedFile(entry.Name()) {
+			continue
+		}
+
+		if info, err := entry.Info(); err == nil {
+			rotated = append(rotated, info)
+		}
+	}
+
+	// We sort the files from the most recent to the oldest
+	sort.Slice(rotated, func(i, j int) bool {
+		if ti, tj := rotated[i].ModTime(), rotated[j].ModTime(); !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return rotated[i].Name() > rotated[j].Name()
+	})
+
+	// Without time rotation, the backups of the current file are already limited by the size rotation,
+	// so only the files of the previous runs are counted
+	currentBackup := filepath.Base(target.path) + "."
+	count := 0
+	for _, info := range rotated {
+		counted := options.period > 0 || options.runName != "" && !strings.HasPrefix(info.Name(), currentBackup)
+		expired := options.maxAge > 0 && now.Sub(info.ModTime()) > options.maxAge
+		exceeded := counted && count >= options.maxBackups
+		if counted {
+			count++
+		}
+		if expired || exceeded {
+			if err := removeIfExists(filepath.Join(folder, info.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
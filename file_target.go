@@ -0,0 +1,190 @@
+package multilogger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileTarget represents a log file. It is shared by all file hooks (and their clones)
+// that write to the same path, so a rotation done by one of them is seen by all others.
+// All the attributes are protected by the mutex of the target.
+type fileTarget struct {
+	mutex sync.Mutex
+	base  string    // The logical name of the file
+	path  string    // The name of the file actually written (differs from base if time rotation is enabled)
+	start time.Time // The beginning of the period of the file actually written (if time rotation is enabled)
+	file  *os.File
+	size  int64
+
+	compressions compressionQueue // Compressions (and shifting of compressed backups) done in background
+	rotations    int              // Number of rotations, used to generate unique names
+	reopen       bool             // Indicates that the file should be reopened on the next write
+	lastCheck    time.Time        // Last time we checked if the file has been moved
+}
+
+// getFileTarget returns the target associated with the logical name.
+func getFileTarget(base string) *fileTarget {
+	fileTargetsMutex.Lock()
+	defer fileTargetsMutex.Unlock()
+	target := fileTargets[base]
+	if target == nil {
+		target = &fileTarget{base: base, path: base}
+		fileTargets[base] = target
+	}
+	return target
+}
+
+// open opens the file in append mode. It returns true if the file already existed.
+func (target *fileTarget) open(options *fileOptions) (exists bool, err error) {
+	logDir := filepath.Dir(target.path)
+	if _, err := os.Stat(logDir); os.IsNotExist(err) && options.createDirs {
+		// Log directory doesn't exist, create it
+		if err := os.MkdirAll(logDir, options.dirPerm); err != nil {
+			return false, err
+		}
+		if options.chmod {
+			if err := os.Chmod(logDir, options.dirPerm); err != nil {
+				return false, err
+			}
+		}
+	} else if _, err := os.Stat(target.path); err == nil {
+		exists = true
+	}
+
+	file, err := os.OpenFile(target.path, os.O_CREATE|os.O_APPEND|os.O_RDWR, options.perm)
+	if err != nil {
+		return
+	}
+	var info os.FileInfo
+	if options.chmod {
+		err = os.Chmod(target.path, options.perm)
+	}
+	if err == nil {
+		info, err = file.Stat()
+	}
+	if err != nil {
+		file.Close()
+		return
+	}
+	// A reopen requested while the file was closed is fulfilled by this opening
+	target.file, target.size, target.reopen = file, info.Size(), false
+	return
+}
+
+// close closes the underlying file, it will be reopened on the next write.
+func (target *fileTarget) close() error {
+	if target.file == nil {
+		return nil
+	}
+	err := target.file.Close()
+	target.file, target.size = nil, 0
+	return err
+}
+
+// mustReopen indicates if the file must be closed and reopened, either because it has been
+// explicitly requested or because the file has been moved or deleted by another process.
+func (target *fileTarget) mustReopen(options *fileOptions) bool {
+	if target.file == nil {
+		return false
+	}
+	if target.reopen {
+		target.reopen = false
+		return true
+	}
+	if !options.watch || time.Since(target.lastCheck) < options.watchInterval {
+		return false
+	}
+	target.lastCheck = time.Now()
+	current, err := target.file.Stat()
+	if err != nil {
+		return true
+	}
+	onDisk, err := os.Stat(target.path)
+	return err != nil || !os.SameFile(current, onDisk)
+}
+
+// shouldRotate indicates if writing length bytes would exceed the maximum size of the file.
+func (target *fileTarget) shouldRotate(options *fileOptions, length int) bool {
+	return options.maxSize > 0 && target.file != nil && target.size > 0 && target.size+int64(length) > options.maxSize
+}
+
+// rotate closes the current file and shifts the existing backups. The file is not reopened here,
+// that will be done on the next write. If compression is enabled, the backups are shifted and the new
+// backup is compressed in background to avoid blocking the writers, report is called if an error occurs.
+func (target *fileTarget) rotate(options *fileOptions, report func(error)) error {
+	if err := target.close(); err != nil {
+		return err
+	}
+	if options.maxBackups <= 0 {
+		return removeIfExists(target.path)
+	}
+	if options.compression == nil {
+		return shiftBackups(options, target.path, target.path, "")
+	}
+
+	// The file is moved aside, the backups are shifted by the compression queue to ensure that
+	// they are never renamed while being compressed
+	target.rotations++
+	staging := fmt.Sprintf("%s.rotating-%d-%d", target.path, processID, target.rotations)
+	if err := renameIfExists(target.path, staging); err != nil {
+		return err
+	}
+	path, compression := target.path, options.compression
+	target.compressions.push(func() {
+		err := shiftBackups(options, path, staging, "", compression.Extension)
+		if err == nil {
+			err = compressFile(options.backupName(path, 1), compression)
+		}
+		if err != nil && report != nil {
+			report(err)
+		}
+	})
+	return nil
+}
+
+// shiftBackups shifts the existing backups of path (with each of the extensions) and renames source
+// as the first backup.
+func shiftBackups(options *fileOptions, path, source string, extensions ...string) error {
+	for _, ext := range extensions {
+		if err := removeIfExists(options.backupName(path, options.maxBackups) + ext); err != nil {
+			return err
+		}
+		for i := options.maxBackups - 1; i >= 1; i-- {
+			if err := renameIfExists(options.backupName(path, i)+ext, options.backupName(path, i+1)+ext); err != nil {
+				return err
+			}
+		}
+	}
+	return renameIfExists(source, options.backupName(path, 1))
+}
+
+func (target *fileTarget) Write(buffer []byte) (int, error) {
+	if target.file == nil {
+		return 0, fmt.Errorf("file %s is not opened", target.path)
+	}
+	n, err := target.file.Write(buffer)
+	target.size += int64(n)
+	return n, err
+}
+
+func removeIfExists(filename string) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func renameIfExists(source, target string) error {
+	if err := os.Rename(source, target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+var (
+	fileTargets      = make(map[string]*fileTarget)
+	fileTargetsMutex sync.Mutex
+)
@@ -3,11 +3,17 @@ package multilogger
 import (
 	"fmt"
 	"os"
+	"reflect"
+	"regexp"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	multicolor "github.com/coveooss/multilogger/color"
+	"github.com/coveooss/multilogger/errors"
+	"github.com/coveooss/multilogger/reutils"
 	"github.com/sirupsen/logrus"
 )
 
@@ -21,6 +27,28 @@ const (
 	durationPrecisionEnvVar = "MULTILOGGER_DURATION_PRECISION"
 )
 
+// OutputMode represents the rendering mode of a Formatter.
+type OutputMode uint8
+
+const (
+	// TextMode renders entries using the format string (default).
+	TextMode OutputMode = iota
+	// JSONMode renders each entry as an indented JSON object.
+	JSONMode
+	// NDJSONMode renders each entry as a compact JSON object on a single line (newline delimited JSON).
+	NDJSONMode
+	// LogfmtMode renders each entry as a single line of key=value pairs (https://brandur.org/logfmt).
+	LogfmtMode
+	// TemplateMode renders each entry using the format string as a text/template (see TemplateEntry).
+	TemplateMode
+)
+
+var formatModes = map[string]OutputMode{
+	"json":   JSONMode,
+	"ndjson": NDJSONMode,
+	"logfmt": LogfmtMode,
+}
+
 // NewFormatter creates a new formatter with color setting and takes the first defined format string as the log format.
 func NewFormatter(color bool, formats ...interface{}) *Formatter {
 	f := &Formatter{color: color}
@@ -30,13 +58,43 @@ func NewFormatter(color bool, formats ...interface{}) *Formatter {
 
 // Formatter implements logrus.Formatter interface.
 type Formatter struct {
-	// Available standard keys: time, delay, globaldelay, delta, message, level, module, file, line, func.
+	// Available standard keys: time, delay, globaldelay, delta, message, level, module, file, line, func, caller,
+	// pid, hostname, exe, goroutine, version, stack.
 	// Also can include custom fields but limited to strings.
 	// All of fields need to be wrapped inside %% i.e %time% %message%
 	TimestampFormat string
 	FormatDuration  func(time.Duration) string
 	FormatCaller    func(*runtime.Frame) string
 
+	// CallerPath allows user to shorten the file paths rendered by %file% and %caller% (default is FullPath).
+	CallerPath PathMode
+
+	// CallerPrefixes allows user to define additional prefixes trimmed from the file paths with RelativePath.
+	CallerPrefixes []string
+
+	// ShortFunctions allows user to render the functions without their package path (i.e. pkg.Type.Method).
+	ShortFunctions bool
+
+	// FieldFormatters allows user to define how the values of specific fields are rendered by %fields% and
+	// the field tokens (i.e. DurationField, TimeField, BytesField, JSONField or a custom function).
+	FieldFormatters map[string]FieldFormatter
+
+	// TypeFormatters allows user to define how the values of a specific type are rendered by %fields% and
+	// the field tokens if there is no formatter defined for the key.
+	TypeFormatters map[reflect.Type]FieldFormatter
+
+	// FieldOrder allows user to define the fields rendered first by %fields% and the structured modes,
+	// the other fields are rendered in alphabetical order.
+	FieldOrder []string
+
+	// InsertionOrder allows user to render the fields in the order they have been added to the logger
+	// (through WithField and WithFields) after the fields of FieldOrder. The order is only tracked while
+	// a hook requires it, so it must be set before the fields are added.
+	InsertionOrder bool
+
+	// ExcludedFields allows user to define fields that are never rendered by %fields% and the structured modes.
+	ExcludedFields []string
+
 	// RoundDuration allows user to define the granularity of durations
 	RoundDuration time.Duration
 
@@ -47,23 +105,48 @@ type Formatter struct {
 	// LevelName allows user to rename default level name.
 	LevelName map[logrus.Level]string
 
+	// Mode allows user to select the rendering of the entries. If it is not set, the mode is determined
+	// by the format string ("json", "ndjson" and "logfmt" select the corresponding mode and a format
+	// containing {{ is considered as a template). Changes are considered on the next call to SetLogFormat.
+	Mode OutputMode
+
+	// KeyNames allows user to rename the keys used for the standard tokens in structured modes (JSON and logfmt).
+	// The standard token name (i.e. time, level, module, message, delta, delay, globaldelay, caller, version, stack) is
+	// used as key and the token is omitted if the new name is empty.
+	KeyNames map[string]string
+
 	format         string
+	levelFormats   map[logrus.Level]string
+	formatErrors   errors.Array
 	replacer       *replacer
+	levelReplacers map[logrus.Level]*replacer
 	color          bool
 	initOnce       sync.Once
 	replacerLock   sync.Mutex
 	baseTime, last time.Time
+
+	insertionFormat atomic.Bool // Indicates if a format renders the fields in insertion order (readable without lock)
 }
 
 // SetLogFormat initialize the log format with the first defined format in the list.
+//
+// If the format starts with @, it defines specific formats for some levels using the syntax
+// "@default=default format||@level1,level2=format for these levels||@level3=...". The
+// levels that have no specific format use the default format (or the default log format
+// if @default is not specified).
 func (f *Formatter) SetLogFormat(formats ...interface{}) *Formatter {
-	// We delete the current formatter code replacer
-	f.replacer = nil
+	f.replacerLock.Lock()
+	defer f.replacerLock.Unlock()
+	defer f.updateInsertionFormat()
+
+	// We delete the current formatter code replacers
+	f.replacer, f.levelReplacers = nil, nil
+	f.levelFormats, f.formatErrors = nil, nil
 
 	// We set the first non empty format as the current format string
 	for _, format := range formats {
 		if format != "" {
-			f.format = fmt.Sprint(format)
+			f.format = f.parseLevelFormats(fmt.Sprint(format))
 			return f
 		}
 	}
@@ -73,6 +156,90 @@ func (f *Formatter) SetLogFormat(formats ...interface{}) *Formatter {
 	return f
 }
 
+// SetLevelFormat defines a specific format for the supplied levels. If the format is empty,
+// the levels use the default format.
+func (f *Formatter) SetLevelFormat(format string, levels ...logrus.Level) *Formatter {
+	f.replacerLock.Lock()
+	defer f.replacerLock.Unlock()
+	defer f.updateInsertionFormat()
+	for _, level := range levels {
+		if format == "" {
+			delete(f.levelFormats, level)
+		} else {
+			if f.levelFormats == nil {
+				f.levelFormats = make(map[logrus.Level]string)
+			}
+			f.levelFormats[level] = format
+		}
+		delete(f.levelReplacers, level)
+	}
+	return f
+}
+
+// updateInsertionFormat checks if one of the formats renders the fields in insertion order (%fields:insertion%),
+// the caller must hold the replacer lock.
+func (f *Formatter) updateInsertionFormat() {
+	formats := []string{f.format}
+	for _, format := range f.levelFormats {
+		formats = append(formats, format)
+	}
+	for _, format := range formats {
+		for _, match := range reFormat.FindAllString(format, -1) {
+			matches, _ := reutils.MultiMatch(match, reFormat)
+			if matches["token"] != "fields" {
+				continue
+			}
+			for _, attribute := range strings.Split(matches["attributes"], ",") {
+				if strings.EqualFold(strings.TrimSpace(attribute), "insertion") {
+					f.insertionFormat.Store(true)
+					return
+				}
+			}
+		}
+	}
+	f.insertionFormat.Store(false)
+}
+
+// parseLevelFormats extracts the level specific formats and returns the default format.
+func (f *Formatter) parseLevelFormats(format string) string {
+	if !strings.HasPrefix(format, levelFormatPrefix) {
+		return format
+	}
+	result := defaultLogFormat
+	for _, segment := range strings.Split(format[len(levelFormatPrefix):], levelFormatSeparator) {
+		matches := reLevelFormat.FindStringSubmatch(segment)
+		if matches == nil {
+			f.formatErrors = append(f.formatErrors, fmt.Errorf("invalid level format %q, expecting @level1,level2=format", levelFormatPrefix+segment))
+			continue
+		}
+		for _, name := range strings.Split(matches[1], ",") {
+			name = strings.TrimSpace(name)
+			if strings.ToLower(name) == defaultLevelFormat {
+				result = matches[2]
+				continue
+			}
+			level, err := TryParseLogLevel(name)
+			if err != nil {
+				f.formatErrors = append(f.formatErrors, err)
+				continue
+			}
+			if f.levelFormats == nil {
+				f.levelFormats = make(map[logrus.Level]string)
+			}
+			f.levelFormats[level] = matches[2]
+		}
+	}
+	return result
+}
+
+const (
+	levelFormatPrefix    = "@"
+	levelFormatSeparator = "||" + levelFormatPrefix
+	defaultLevelFormat   = "default"
+)
+
+var reLevelFormat = regexp.MustCompile(`(?s)^([\w\s,]+)=(.*)$`)
+
 // SetColor set color mode on the formatter.
 func (f *Formatter) SetColor(color bool) { f.color = color }
 
@@ -81,7 +248,6 @@ func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
 	f.initOnce.Do(f.init)
 
 	output, err := f.doFormat(entry)
-	f.last = entry.Time
 	return []byte(output), err
 }
 
@@ -103,18 +269,8 @@ func (f *Formatter) init() {
 		}
 	}
 	if f.FormatCaller == nil {
-		f.FormatCaller = func(frame *runtime.Frame) (result string) {
-			if frame == nil {
-				return
-			}
-			result = frame.Function
-			if frame.File != "" {
-				if result != "" {
-					result += " "
-				}
-				result += fmt.Sprintf("%s:%d", frame.File, frame.Line)
-			}
-			return
+		f.FormatCaller = func(frame *runtime.Frame) string {
+			return f.formatCaller(frame, f.CallerPath, f.ShortFunctions)
 		}
 	}
 
@@ -0,0 +1,129 @@
+package multilogger
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// PathMode determines how the file paths of the caller are rendered.
+type PathMode uint8
+
+const (
+	// FullPath renders the file path as reported by the runtime (default).
+	FullPath PathMode = iota
+	// RelativePath renders the file path relative to the first matching prefix among Formatter.CallerPrefixes,
+	// the GOPATH folders (src and pkg/mod) or the root of the Go module containing the file.
+	RelativePath
+	// BaseName renders only the name of the file.
+	BaseName
+)
+
+// formatCaller returns the function and the file of the caller.
+func (f *Formatter) formatCaller(frame *runtime.Frame, mode PathMode, short bool) (result string) {
+	if frame == nil {
+		return
+	}
+	result = f.callerFunction(frame, short)
+	if frame.File != "" {
+		if result != "" {
+			result += " "
+		}
+		result += fmt.Sprintf("%s:%d", f.callerFile(frame, mode), frame.Line)
+	}
+	return
+}
+
+// callerFile returns the file of the caller shortened according to the mode.
+func (f *Formatter) callerFile(frame *runtime.Frame, mode PathMode) string {
+	switch mode {
+	case BaseName:
+		return path.Base(frame.File)
+	case RelativePath:
+		return f.relativePath(frame.File)
+	}
+	return frame.File
+}
+
+// callerFunction returns the function of the caller (without its package path if short is set).
+func (f *Formatter) callerFunction(frame *runtime.Frame, short bool) string {
+	if !short {
+		return frame.Function
+	}
+	return functionReplacer.Replace(frame.Function[strings.LastIndex(frame.Function, "/")+1:])
+}
+
+var functionReplacer = strings.NewReplacer("(*", "", "(", "", ")", "")
+
+func (f *Formatter) relativePath(file string) string {
+	for _, prefix := range f.CallerPrefixes {
+		if trimmed, found := trimPathPrefix(file, prefix); found {
+			return trimmed
+		}
+	}
+	for _, prefix := range goPaths {
+		if trimmed, found := trimPathPrefix(file, prefix); found {
+			return trimmed
+		}
+	}
+	if !path.IsAbs(file) && !filepath.IsAbs(file) {
+		// The path is already relative (i.e. built with -trimpath)
+		return file
+	}
+	if root := moduleRoot(path.Dir(file)); root != "" {
+		if trimmed, found := trimPathPrefix(file, root); found {
+			return trimmed
+		}
+	}
+	return file
+}
+
+func trimPathPrefix(file, prefix string) (string, bool) {
+	prefix = strings.TrimSuffix(filepath.ToSlash(prefix), "/") + "/"
+	if prefix == "/" || !strings.HasPrefix(file, prefix) {
+		return file, false
+	}
+	return file[len(prefix):], true
+}
+
+// goPaths contains the folders of the GOPATH that contain sources.
+var goPaths = func() (result []string) {
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			gopath = filepath.Join(home, "go")
+		}
+	}
+	for _, folder := range filepath.SplitList(gopath) {
+		result = append(result, filepath.Join(folder, "pkg", "mod"), filepath.Join(folder, "src"))
+	}
+	return
+}()
+
+// moduleRoots caches the root of the Go module containing a folder.
+var moduleRoots sync.Map
+
+// moduleRoot returns the nearest parent folder (including itself) containing a go.mod file.
+func moduleRoot(folder string) string {
+	if root, found := moduleRoots.Load(folder); found {
+		return root.(string)
+	}
+	var root string
+	for current := folder; ; {
+		if _, err := os.Stat(filepath.Join(current, "go.mod")); err == nil {
+			root = current
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+	moduleRoots.Store(folder, root)
+	return root
+}
@@ -0,0 +1,137 @@
+package multilogger
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FieldFormatter converts the value of a field into the string rendered by the formatter.
+type FieldFormatter func(f *Formatter, value interface{}) string
+
+// Predefined field formatters, they are also available as attributes of the field tokens
+// (i.e. %myfield:json% or %fields:bytes%).
+var (
+	// SprintField renders the value with fmt.Sprint (default).
+	SprintField FieldFormatter = func(f *Formatter, value interface{}) string { return fmt.Sprint(value) }
+
+	// JSONField renders the value as compact JSON.
+	JSONField FieldFormatter = func(f *Formatter, value interface{}) string { return string(marshalJSON(value)) }
+
+	// DurationField renders durations with the FormatDuration function of the formatter.
+	DurationField FieldFormatter = func(f *Formatter, value interface{}) string {
+		if duration, isDuration := value.(time.Duration); isDuration && f.FormatDuration != nil {
+			return f.FormatDuration(duration)
+		}
+		return fmt.Sprint(value)
+	}
+
+	// TimeField renders times with the TimestampFormat of the formatter.
+	TimeField FieldFormatter = func(f *Formatter, value interface{}) string {
+		if t, isTime := value.(time.Time); isTime {
+			return t.Format(f.TimestampFormat)
+		}
+		return fmt.Sprint(value)
+	}
+
+	// BytesField renders integers as a human readable size (i.e. 1.5 KiB).
+	BytesField FieldFormatter = func(f *Formatter, value interface{}) string {
+		switch v := reflect.ValueOf(value); v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return FormatBytes(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if size := v.Uint(); size <= 1<<63-1 {
+				return FormatBytes(int64(size))
+			}
+		}
+		return fmt.Sprint(value)
+	}
+)
+
+var fieldFormatters = map[string]FieldFormatter{
+	"json":     JSONField,
+	"duration": DurationField,
+	"time":     TimeField,
+	"bytes":    BytesField,
+}
+
+// FormatBytes returns a human readable representation of a size expressed in bytes.
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit && size > -unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exponent := float64(size)/unit, 0
+	for ; (value >= unit || value <= -unit) && exponent < 5; exponent++ {
+		value /= unit
+	}
+	result := strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0")
+	return fmt.Sprintf("%s %ciB", result, "KMGTPE"[exponent])
+}
+
+// fieldValue renders the value of a field. The formatter explicitly requested by the token has precedence
+// over the formatters defined for the key and then for the type of the value.
+func (f *Formatter) fieldValue(key string, value interface{}, formatter FieldFormatter) string {
+	if formatter == nil {
+		formatter = f.FieldFormatters[key]
+	}
+	if formatter == nil && value != nil {
+		formatter = f.TypeFormatters[reflect.TypeOf(value)]
+	}
+	if formatter == nil {
+		formatter = SprintField
+	}
+	return formatter(f, value)
+}
+
+// sortFields sorts the keys of the fields in the order they should be rendered: the priority keys
+// (or Formatter.FieldOrder), then the keys in insertion order if requested and finally the other keys
+// in alphabetical order.
+func (f *Formatter) sortFields(entry *logrus.Entry, keys []string, priority []string, insertion bool) []string {
+	sort.Strings(keys)
+	if len(priority) == 0 {
+		priority = f.FieldOrder
+	}
+	order := priority
+	if insertion || f.InsertionOrder {
+		if inserted, _ := entry.Data[orderFieldName].([]string); len(inserted) > 0 {
+			order = append(append(make([]string, 0, len(priority)+len(inserted)), priority...), inserted...)
+		}
+	}
+	if len(order) == 0 {
+		return keys
+	}
+
+	rank := make(map[string]int, len(order))
+	for i, key := range order {
+		if _, isSet := rank[key]; !isSet {
+			rank[key] = i
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		ri, isRanked := rank[keys[i]]
+		rj, isOtherRanked := rank[keys[j]]
+		if isRanked && isOtherRanked {
+			return ri < rj
+		}
+		return isRanked && !isOtherRanked
+	})
+	return keys
+}
+
+// isExcluded checks if the field should never be rendered.
+func (f *Formatter) isExcluded(key string, excluded []string) bool {
+	for _, lists := range [][]string{excluded, f.ExcludedFields} {
+		for _, name := range lists {
+			if name == key {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -20,15 +20,21 @@ func _() {
 	_ = x[tokenFile-9]
 	_ = x[tokenLine-10]
 	_ = x[tokenCaller-11]
-	_ = x[tokenFields-12]
-	_ = x[fieldTokenType-13]
-	_ = x[fieldWrapperTokenType-14]
-	_ = x[keyWrapperTokenType-15]
+	_ = x[tokenPid-12]
+	_ = x[tokenHostname-13]
+	_ = x[tokenExe-14]
+	_ = x[tokenGoroutine-15]
+	_ = x[tokenVersion-16]
+	_ = x[tokenStack-17]
+	_ = x[tokenFields-18]
+	_ = x[fieldTokenType-19]
+	_ = x[fieldWrapperTokenType-20]
+	_ = x[keyWrapperTokenType-21]
 }
 
-const _tokenType_name = "unsetTokenTypeMessageLevelTimeDeltaDelayGlobalDelayModuleFuncFileLineCallerFieldsfieldTokenTypefieldWrapperTokenTypekeyWrapperTokenType"
+const _tokenType_name = "unsetTokenTypeMessageLevelTimeDeltaDelayGlobalDelayModuleFuncFileLineCallerPidHostnameExeGoroutineVersionStackFieldsfieldTokenTypefieldWrapperTokenTypekeyWrapperTokenType"
 
-var _tokenType_index = [...]uint8{0, 14, 21, 26, 30, 35, 40, 51, 57, 61, 65, 69, 75, 81, 95, 116, 135}
+var _tokenType_index = [...]uint8{0, 14, 21, 26, 30, 35, 40, 51, 57, 61, 65, 69, 75, 78, 86, 89, 98, 105, 110, 116, 130, 151, 170}
 
 func (i tokenType) String() string {
 	if i >= tokenType(len(_tokenType_index)-1) {
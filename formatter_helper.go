@@ -3,7 +3,6 @@ package multilogger
 import (
 	"fmt"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 
@@ -16,18 +15,31 @@ import (
 
 func (f *Formatter) doFormat(entry *logrus.Entry) (string, error) {
 	f.replacerLock.Lock()
-	if f.replacer == nil {
-		if err := f.presetFormatString(); err != nil {
-			return "", err
-		}
-	}
 	defer f.replacerLock.Unlock()
+	// The time of the last entry is updated while the lock is held (hooks could be fired concurrently)
+	defer func() { f.last = entry.Time }()
+	r, err := f.getReplacer(entry.Level)
+	if err != nil {
+		return "", err
+	}
 
-	output := f.replacer.format + "\n"
+	switch r.mode {
+	case JSONMode, NDJSONMode:
+		return f.formatJSON(entry, r.mode)
+	case LogfmtMode:
+		return f.formatLogfmt(entry)
+	case TemplateMode:
+		return f.formatTemplate(entry, r.template)
+	}
+
+	output := r.format + "\n"
 
 	usedFields := make(map[string]uint, len(entry.Data))
+	for _, hidden := range hiddenFields {
+		usedFields[hidden]++
+	}
 	var printFields []*fieldReplacer
-	for _, replacer := range f.replacer.fields {
+	for _, replacer := range r.fields {
 		result, delayed := replacer.replace(entry, usedFields)
 		if delayed != nil {
 			printFields = append([]*fieldReplacer{delayed}, printFields...)
@@ -39,18 +51,19 @@ func (f *Formatter) doFormat(entry *logrus.Entry) (string, error) {
 		for _, replacer := range printFields {
 			result := make([]string, 0, len(entry.Data))
 			for key := range entry.Data {
-				if usedFields[key] > 0 || entry.Data[key] == nil && replacer.ignoreEmpty {
+				if usedFields[key] > 0 || entry.Data[key] == nil && replacer.ignoreEmpty || f.isExcluded(key, replacer.excludedFields) {
 					continue
 				}
 				result = append(result, key)
 			}
-			sort.Strings(result)
+			result = f.sortFields(entry, result, replacer.fieldOrder, replacer.insertionOrder)
 			for i, key := range result {
+				value := f.fieldValue(key, entry.Data[key], replacer.fieldFormatter)
 				if replacer.noKeyFieldFormat {
-					result[i] = fmt.Sprintf("%s=%v", key, entry.Data[key])
+					result[i] = fmt.Sprintf("%s=%s", key, value)
 				} else {
-					result[i] = f.replacer.keyReplacer.formatValue(key+"=", entry.Level) +
-						f.replacer.fieldReplacer.formatValue(entry.Data[key], entry.Level)
+					result[i] = r.keyReplacer.formatValue(key+"=", entry.Level) +
+						r.fieldReplacer.formatValue(value, entry.Level)
 				}
 			}
 			fields := strings.Join(result, " ")
@@ -60,16 +73,93 @@ func (f *Formatter) doFormat(entry *logrus.Entry) (string, error) {
 		}
 	}
 
-	return output, nil
+	return alignMultiline(output), nil
+}
+
+// getReplacer returns the replacer used to format entries of the level. The replacers are
+// created on first use and kept unless their format is invalid.
+func (f *Formatter) getReplacer(level logrus.Level) (r *replacer, err error) {
+	if format, isSet := f.levelFormats[level]; !isSet {
+		if f.replacer == nil {
+			f.replacer, err = f.newReplacer(f.format)
+		}
+		r = f.replacer
+	} else if r = f.levelReplacers[level]; r == nil {
+		if r, err = f.newReplacer(format); r != nil {
+			if f.levelReplacers == nil {
+				f.levelReplacers = make(map[logrus.Level]*replacer)
+			}
+			f.levelReplacers[level] = r
+		}
+	}
+	if len(f.formatErrors) > 0 {
+		err = append(f.formatErrors, err).AsError()
+		f.formatErrors = nil
+	}
+	return
+}
+
+// usesToken indicates if the format used for the level contains the token.
+func (f *Formatter) usesToken(level logrus.Level, tt tokenType) bool {
+	return f.usesField(func(field *fieldReplacer) bool { return field.tt == tt }, level)
+}
+
+// usesInsertionOrder indicates if the fields are rendered in the order they have been added for any level.
+// It does not lock the formatter since it is called each time fields are added to a logger.
+func (f *Formatter) usesInsertionOrder() bool {
+	return f.InsertionOrder || f.insertionFormat.Load()
+}
+
+// usesField indicates if the format used for one of the levels contains a field that matches the condition.
+func (f *Formatter) usesField(match func(*fieldReplacer) bool, levels ...logrus.Level) bool {
+	f.initOnce.Do(f.init)
+	f.replacerLock.Lock()
+	defer f.replacerLock.Unlock()
+	for _, level := range levels {
+		r, err := f.getReplacer(level)
+		if r == nil {
+			continue
+		}
+		if err != nil {
+			// The errors are kept to be reported on the next call to Format
+			f.formatErrors = append(f.formatErrors, err)
+		}
+		for _, field := range r.fields {
+			if match(field) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func (f *Formatter) presetFormatString() error {
+// newReplacer compiles the format. A replacer is returned even if there are
+// errors unless the format cannot be used at all.
+func (f *Formatter) newReplacer(format string) (*replacer, error) {
 	var errors errors.Array
-	f.replacer = &replacer{Formatter: f}
-	r := f.replacer
+	r := &replacer{Formatter: f, mode: f.Mode}
+	if r.mode == TextMode {
+		if mode, isMode := formatModes[strings.ToLower(strings.TrimSpace(format))]; isMode {
+			r.mode = mode
+		} else if strings.Contains(format, "{{") {
+			r.mode = TemplateMode
+		}
+	}
+	if r.mode == TemplateMode {
+		var err error
+		if r.template, err = f.parseTemplate(format); err != nil {
+			// The replacer is not kept to report the error on each call
+			return nil, err
+		}
+		return r, nil
+	}
+	if r.mode != TextMode {
+		// Structured modes do not use the format string
+		return r, nil
+	}
 	r.keyReplacer = r.newField(true)
 	r.fieldReplacer = r.newField(false)
-	r.format = reFormat.ReplaceAllStringFunc(f.format, func(match string) (result string) {
+	r.format = reFormat.ReplaceAllStringFunc(format, func(match string) (result string) {
 		matches, _ := reutils.MultiMatch(match, reFormat)
 
 		result = replacementToken
@@ -77,7 +167,7 @@ func (f *Formatter) presetFormatString() error {
 		if field := matches["field"]; field != "" {
 			fieldReplacer.tt = fieldTokenType
 			fieldReplacer.fieldName = field
-			f.replacer.fields = append(r.fields, fieldReplacer)
+			r.fields = append(r.fields, fieldReplacer)
 		} else if token := matches["token"]; token != "" {
 			fieldReplacer.tt = reverseTokens[token]
 			if fieldReplacer.tt == unsetTokenType {
@@ -109,11 +199,22 @@ func (f *Formatter) presetFormatString() error {
 		attributes := strings.Split(matches["attributes"], ",")
 		colors := make([]interface{}, 0, len(attributes))
 		for _, attribute := range attributes {
+			if matches := reFieldList.FindStringSubmatch(attribute); matches != nil {
+				// The keys are case sensitive, so we process the list before converting the attribute
+				if strings.EqualFold(matches[1], "order") {
+					fieldReplacer.fieldOrder = strings.Fields(matches[2])
+				} else {
+					fieldReplacer.excludedFields = strings.Fields(matches[2])
+				}
+				continue
+			}
 			attribute = strings.ToLower(strings.TrimSpace(attribute))
 			if attribute == "" {
 				continue
 			}
 			switch attribute {
+			case "insertion":
+				fieldReplacer.insertionOrder = true
 			case "color":
 				fieldReplacer.color = true
 			case "upper":
@@ -134,6 +235,22 @@ func (f *Formatter) presetFormatString() error {
 				fieldReplacer.wrapper = angleBrackets
 			case "space":
 				fieldReplacer.addSpace = true
+			case "full", "fullpath":
+				fieldReplacer.pathMode = new(PathMode)
+			case "relative":
+				mode := RelativePath
+				fieldReplacer.pathMode = &mode
+			case "base", "basename":
+				mode := BaseName
+				fieldReplacer.pathMode = &mode
+			case "short":
+				fieldReplacer.shortFunction = true
+			case "json", "duration", "time", "bytes":
+				fieldReplacer.fieldFormatter = fieldFormatters[attribute]
+			case "indent":
+				fieldReplacer.multiline = indentMultiline
+			case "repeat":
+				fieldReplacer.multiline = repeatMultiline
 			case "none":
 				fieldReplacer.noKeyFieldFormat = true
 			case "ignore", "ignoreempty":
@@ -172,13 +289,15 @@ func (f *Formatter) presetFormatString() error {
 		r.fields[index].position = uint(begin)
 	}
 
-	return errors.AsError()
+	return r, errors.AsError()
 }
 
 // https://regex101.com/r/SPI8hT/1
 var (
-	reFormat = regexp.MustCompile(`%(?:(?P<width>-?\d+)?(?:\.(?P<limit>\d+))?(?:(?P<token>(?:time|(?:global)?delay|delta|message|msg|level|lvl|module|func|file|line|caller|fields|key|field))|(?P<field>\w+)))?(?i)(?::(?P<attributes>(?:[,+\-\s]*(?:color|upper|lower|title|none|key|ignore(?:empty)?|space|parens|parenthesis|(?:square|curly|round|angle)(?:brackets)?|(?:bg)?(?:hi)?(?:black|red|green|yellow|blue|magenta|cyan|white)|(?:bold|faint|italic|underline|blinkslow|blinkrapid|reversevideo|concealed|crossedout|reset))\s*)+))?%`)
+	reFormat = regexp.MustCompile(`%(?:(?P<width>-?\d+)?(?:\.(?P<limit>\d+))?(?:(?P<token>(?:time|(?:global)?delay|delta|message|msg|level|lvl|module|func|file|line|caller|pid|hostname|exe(?:cutable)?|goroutine|version|stack|fields|key|field))|(?P<field>\w+)))?(?i)(?::(?P<attributes>(?:[,+\-\s]*(?:color|upper|lower|title|none|key|ignore(?:empty)?|space|indent|repeat|full(?:path)?|relative|base(?:name)?|short|json|duration|time|bytes|insertion|(?:order|exclude)\([\w\s.\-]*\)|parens|parenthesis|(?:square|curly|round|angle)(?:brackets)?|(?:bg)?(?:hi)?(?:black|red|green|yellow|blue|magenta|cyan|white)|(?:bold|faint|italic|underline|blinkslow|blinkrapid|reversevideo|concealed|crossedout|reset))\s*)+))?%`)
 	reset    = string([]byte{27, 91, 48, 109})
+
+	reFieldList = regexp.MustCompile(`(?i)^\s*(order|exclude)\(([^)]*)\)\s*$`)
 )
 
 type transformType uint8
@@ -190,6 +309,19 @@ const (
 	titleTransform
 )
 
+type multilineType uint8
+
+const (
+	noMultiline multilineType = iota
+	indentMultiline
+	repeatMultiline
+)
+
+// Markers inserted in the output to align the continuation lines of multiline values.
+const continuationMarker = "\x00continue\x00"
+
+var multilineMarkers = []string{"", "\x00indent\x00", "\x00repeat\x00"}
+
 type bracketType uint8
 
 const (
@@ -215,6 +347,12 @@ const (
 	tokenFile
 	tokenLine
 	tokenCaller
+	tokenPid
+	tokenHostname
+	tokenExe
+	tokenGoroutine
+	tokenVersion
+	tokenStack
 	tokenFields
 	fieldTokenType
 	fieldWrapperTokenType
@@ -229,6 +367,7 @@ func init() {
 	reverseTokens["lvl"] = tokenLevel
 	reverseTokens["msg"] = tokenMessage
 	reverseTokens["global"] = tokenGlobalDelay
+	reverseTokens["executable"] = tokenExe
 }
 
 var reverseTokens map[string]tokenType
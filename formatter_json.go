@@ -0,0 +1,48 @@
+package multilogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+func (f *Formatter) formatJSON(entry *logrus.Entry, mode OutputMode) (string, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	f.structuredValues(entry, func(key string, value interface{}) {
+		if buffer.Len() > 1 {
+			buffer.WriteByte(',')
+		}
+		buffer.Write(marshalJSON(key))
+		buffer.WriteByte(':')
+		buffer.Write(marshalJSON(value))
+	})
+	buffer.WriteByte('}')
+
+	if mode == JSONMode {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, buffer.Bytes(), "", "  "); err != nil {
+			return "", err
+		}
+		buffer = indented
+	}
+	buffer.WriteByte('\n')
+	return buffer.String(), nil
+}
+
+// marshalJSON converts the value into JSON, values that cannot be converted are rendered as string.
+func marshalJSON(value interface{}) []byte {
+	if err, isError := value.(error); isError {
+		value = err.Error()
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		buffer.Reset()
+		encoder.Encode(fmt.Sprint(value))
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))
+}
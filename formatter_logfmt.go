@@ -0,0 +1,70 @@
+package multilogger
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+)
+
+func (f *Formatter) formatLogfmt(entry *logrus.Entry) (string, error) {
+	var builder strings.Builder
+	f.structuredValues(entry, func(key string, value interface{}) {
+		if builder.Len() > 0 {
+			builder.WriteByte(' ')
+		}
+		builder.WriteString(logfmtKey(key))
+		builder.WriteByte('=')
+		builder.WriteString(logfmtValue(value))
+	})
+	builder.WriteByte('\n')
+	return builder.String(), nil
+}
+
+// logfmtKey replaces the characters that are not allowed in a logfmt key.
+func logfmtKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, key)
+	if key == "" {
+		return "_"
+	}
+	return key
+}
+
+// logfmtValue converts the value into a string that is quoted if it contains spaces, quotes,
+// equal signs or control characters. Composite values are rendered as JSON.
+func logfmtValue(value interface{}) string {
+	var result string
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		result = value
+	case error:
+		result = value.Error()
+	case fmt.Stringer:
+		result = value.String()
+	default:
+		switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+			result = string(marshalJSON(value))
+		default:
+			result = fmt.Sprint(value)
+		}
+	}
+	if result == "" || strings.IndexFunc(result, needsQuoting) >= 0 {
+		return strconv.Quote(result)
+	}
+	return result
+}
+
+func needsQuoting(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r)
+}
@@ -2,8 +2,10 @@ package multilogger
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/acarl005/stripansi"
@@ -14,6 +16,8 @@ import (
 
 type replacer struct {
 	*Formatter
+	mode          OutputMode
+	template      *template.Template
 	format        string
 	fields        []*fieldReplacer
 	keyReplacer   *fieldReplacer
@@ -32,6 +36,7 @@ type fieldReplacer struct {
 	*replacer
 	transform        transformType
 	wrapper          bracketType
+	multiline        multilineType
 	addSpace         bool
 	ignoreEmpty      bool
 	printKey         bool
@@ -41,6 +46,12 @@ type fieldReplacer struct {
 	fieldName        string
 	color            bool
 	width            *int
+	pathMode         *PathMode
+	shortFunction    bool
+	fieldFormatter   FieldFormatter
+	fieldOrder       []string
+	excludedFields   []string
+	insertionOrder   bool
 	limit            *uint
 	position         uint
 	out              map[logrus.Level]func(...interface{}) string
@@ -51,21 +62,6 @@ func (r *fieldReplacer) replace(entry *logrus.Entry, used map[string]uint) (stri
 	var field string
 	key, printKey := r.tt.String(), r.printKey
 
-	computeduration := func(begin time.Time) string {
-		delay := entry.Time.Sub(begin)
-		round := r.RoundDuration
-		if round == 0 {
-			round = roundDuration
-		}
-		if delay = delay.Round(round); delay == 0 {
-			return fmt.Sprintf("<%s", r.FormatDuration(round))
-		}
-		if r.FormatDuration != nil {
-			return r.FormatDuration(delay)
-		}
-		return delay.String()
-	}
-
 	// Find the right replacement
 	switch r.tt {
 	case fieldTokenType:
@@ -75,39 +71,79 @@ func (r *fieldReplacer) replace(entry *logrus.Entry, used map[string]uint) (stri
 			field = ""
 			printKey = false
 		} else {
-			field = fmt.Sprint(value)
+			field = r.fieldValue(key, value, r.fieldFormatter)
 		}
 
 		used[key]++
+	case tokenModule:
+		field = r.tokenValue(r.tt, entry)
+		used[moduleFieldName]++
+	case tokenFields:
+		return replacementToken, r
+	case tokenStack:
+		if field = r.tokenValue(r.tt, entry); field != "" {
+			// The stack is rendered on the lines following the entry
+			field = "\n" + field
+		}
+	case tokenFunc, tokenFile, tokenCaller:
+		if entry.Caller == nil || r.pathMode == nil && !r.shortFunction {
+			field = r.tokenValue(r.tt, entry)
+			break
+		}
+		// The attributes of the token override the formatter settings
+		mode := r.CallerPath
+		if r.pathMode != nil {
+			mode = *r.pathMode
+		}
+		short := r.ShortFunctions || r.shortFunction
+		switch r.tt {
+		case tokenFunc:
+			field = r.callerFunction(entry.Caller, short)
+		case tokenFile:
+			field = r.callerFile(entry.Caller, mode)
+		default:
+			field = r.formatCaller(entry.Caller, mode, short)
+		}
+	default:
+		field = r.tokenValue(r.tt, entry)
+	}
+
+	return r.format(key, field, printKey, entry.Level), nil
+}
+
+// tokenValue returns the value of a standard token for the entry.
+func (f *Formatter) tokenValue(tt tokenType, entry *logrus.Entry) (field string) {
+	computeduration := func(begin time.Time) string { return f.formatDelay(entry.Time.Sub(begin)) }
+
+	switch tt {
 	case tokenMessage:
 		field = entry.Message
 	case tokenLevel:
-		field = r.LevelName[entry.Level]
+		field = f.LevelName[entry.Level]
 		if field == "" {
 			field = fmt.Sprint(entry.Level)
 		}
 	case tokenTime:
 		if globalZone != nil {
-			field = entry.Time.In(globalZone).Format(r.TimestampFormat)
+			field = entry.Time.In(globalZone).Format(f.TimestampFormat)
 		} else {
-			field = entry.Time.Format(r.TimestampFormat)
+			field = entry.Time.Format(f.TimestampFormat)
 		}
 	case tokenDelta:
-		field = computeduration(r.last)
+		field = computeduration(f.last)
 	case tokenDelay:
-		field = computeduration(r.baseTime)
+		field = computeduration(f.baseTime)
 	case tokenGlobalDelay:
 		field = computeduration(globalTime)
 	case tokenModule:
 		field = fmt.Sprint(entry.Data[moduleFieldName])
-		used[moduleFieldName]++
 	case tokenFunc:
 		if entry.Caller != nil {
-			field = entry.Caller.Function
+			field = f.callerFunction(entry.Caller, f.ShortFunctions)
 		}
 	case tokenFile:
 		if entry.Caller != nil {
-			field = entry.Caller.File
+			field = f.callerFile(entry.Caller, f.CallerPath)
 		}
 	case tokenLine:
 		if entry.Caller != nil {
@@ -115,13 +151,44 @@ func (r *fieldReplacer) replace(entry *logrus.Entry, used map[string]uint) (stri
 		}
 	case tokenCaller:
 		if entry.Caller != nil {
-			field = r.FormatCaller(entry.Caller)
+			field = f.FormatCaller(entry.Caller)
 		}
-	case tokenFields:
-		return replacementToken, r
+	case tokenPid:
+		field = strconv.Itoa(processID)
+	case tokenHostname:
+		field = processHostname
+	case tokenExe:
+		field = processExecutable
+	case tokenGoroutine:
+		if id, isSet := entry.Data[goroutineFieldName]; isSet {
+			// The entry has been logged by another goroutine (i.e. asynchronous hook)
+			field = fmt.Sprint(id)
+		} else {
+			field = strconv.FormatUint(goroutineID(), 10)
+		}
+	case tokenVersion:
+		if version, isSet := entry.Data[versionFieldName]; isSet {
+			field = fmt.Sprint(version)
+		}
+	case tokenStack:
+		field = f.formatStack(entry, true)
 	}
+	return
+}
 
-	return r.format(key, field, printKey, entry.Level), nil
+// formatDelay returns the delay rounded according to the formatter settings.
+func (f *Formatter) formatDelay(delay time.Duration) string {
+	round := f.RoundDuration
+	if round == 0 {
+		round = roundDuration
+	}
+	if delay = delay.Round(round); delay == 0 {
+		return fmt.Sprintf("<%s", f.FormatDuration(round))
+	}
+	if f.FormatDuration != nil {
+		return f.FormatDuration(delay)
+	}
+	return delay.String()
 }
 
 func (r *fieldReplacer) formatValue(value interface{}, level logrus.Level) string {
@@ -187,7 +254,7 @@ func (r *fieldReplacer) format(key, value string, printKey bool, level logrus.Le
 		r.out[level] = sprint
 		r.outMutex.Unlock()
 	}
-	value = sprint(value)
+	value = sprintLines(sprint, value)
 
 	// Process the prefix
 	if r != r.fieldReplacer && r != r.keyReplacer {
@@ -202,7 +269,62 @@ func (r *fieldReplacer) format(key, value string, printKey bool, level logrus.Le
 		}
 	}
 
+	if r.multiline != noMultiline && strings.Contains(value, "\n") {
+		// The continuation lines are aligned once the whole line has been formatted
+		value = multilineMarkers[r.multiline] + strings.Replace(value, "\n", continuationMarker, -1)
+	}
 	return value
 }
 
+// sprintLines applies sprint on each line individually to ensure that the
+// colors are preserved on continuation lines.
+func sprintLines(sprint func(...interface{}) string, value string) string {
+	if !strings.Contains(value, "\n") {
+		return sprint(value)
+	}
+	lines := strings.Split(value, "\n")
+	for i := range lines {
+		if lines[i] != "" {
+			lines[i] = sprint(lines[i])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// alignMultiline replaces the multiline markers by the prefix of the line (or spaces
+// of the same width) to align the continuation lines.
+func alignMultiline(output string) string {
+	for {
+		start, mode := -1, noMultiline
+		for m := indentMultiline; int(m) < len(multilineMarkers); m++ {
+			if i := strings.Index(output, multilineMarkers[m]); i >= 0 && (start < 0 || i < start) {
+				start, mode = i, m
+			}
+		}
+		if start < 0 {
+			return output
+		}
+
+		prefix := output[strings.LastIndex(output[:start], "\n")+1 : start]
+		output = output[:start] + output[start+len(multilineMarkers[mode]):]
+		end := len(output)
+		for _, marker := range multilineMarkers[1:] {
+			if i := strings.Index(output[start:], marker); i >= 0 && start+i < end {
+				end = start + i
+			}
+		}
+
+		lines := strings.Split(output[start:end], continuationMarker)
+		for i := 1; i < len(lines); i++ {
+			switch {
+			case mode == repeatMultiline:
+				lines[i] = prefix + lines[i]
+			case lines[i] != "":
+				lines[i] = strings.Repeat(" ", len([]rune(stripansi.Strip(prefix)))) + lines[i]
+			}
+		}
+		output = output[:start] + strings.Join(lines, "\n") + output[end:]
+	}
+}
+
 const replacementToken = "💚"
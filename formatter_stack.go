@@ -0,0 +1,120 @@
+package multilogger
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/coveooss/multilogger/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const maxStackDepth = 64
+
+// stackHook is an internal hook that captures the stack trace of the entries at the time they are logged.
+// It is always fired before the other hooks, so the stack trace is available to them even if they are
+// processed asynchronously.
+type stackHook struct {
+	level logrus.Level
+}
+
+func (hook stackHook) Levels() []logrus.Level {
+	var levels []logrus.Level
+	for _, level := range logrus.AllLevels {
+		if level <= hook.level {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+func (hook stackHook) Fire(entry *logrus.Entry) error {
+	if _, isSet := entry.Data[stackFieldName]; !isSet {
+		entry.Data[stackFieldName] = captureStack()
+	}
+	return nil
+}
+
+// captureStack returns the frames of the current goroutine, excluding the logging frames.
+func captureStack() (result []runtime.Frame) {
+	pcs := make([]uintptr, maxStackDepth)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for more := true; more; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		if len(result) == 0 && isLoggingFrame(frame) {
+			continue
+		}
+		result = append(result, frame)
+	}
+	return
+}
+
+// isLoggingFrame checks if the frame belongs to logrus, log/slog or to this package (excluding tests).
+func isLoggingFrame(frame runtime.Frame) bool {
+	for _, pkg := range []string{"github.com/sirupsen/logrus.", "github.com/coveooss/multilogger.", "log/slog."} {
+		if strings.HasPrefix(frame.Function, pkg) && !strings.HasSuffix(frame.File, "_test.go") {
+			return true
+		}
+	}
+	return false
+}
+
+// formatStack renders the error attached to the entry (with its causes) if requested and the stack
+// trace captured when the entry has been logged.
+func (f *Formatter) formatStack(entry *logrus.Entry, withError bool) string {
+	var lines []string
+	if err, isError := entry.Data[logrus.ErrorKey].(error); isError && withError {
+		lines = append(lines, errorLines(err, "")...)
+	}
+	if frames, isStack := entry.Data[stackFieldName].([]runtime.Frame); isStack && len(frames) > 0 {
+		lines = append(lines, "Stack trace:")
+		for i := range frames {
+			lines = append(lines,
+				"  "+f.callerFunction(&frames[i], f.ShortFunctions),
+				fmt.Sprintf("      %s:%d", f.callerFile(&frames[i], f.CallerPath), frames[i].Line))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// errorLines returns the description of the error and all its causes.
+func errorLines(err error, indent string) (lines []string) {
+	if array, isArray := err.(errors.Array); isArray {
+		lines = append(lines, fmt.Sprintf("%sErrors: %d", indent, len(array)))
+	} else {
+		lines = append(lines, indent+"Error: "+firstLine(err.Error()))
+	}
+	for err != nil {
+		switch wrapper := err.(type) {
+		case errors.Array:
+			for _, inner := range wrapper {
+				if inner != nil {
+					lines = append(lines, errorLines(inner, indent+"  ")...)
+				}
+			}
+			return
+		case interface{ Unwrap() []error }:
+			for _, inner := range wrapper.Unwrap() {
+				if inner != nil {
+					lines = append(lines, errorLines(inner, indent+"  ")...)
+				}
+			}
+			return
+		case interface{ Unwrap() error }:
+			if err = wrapper.Unwrap(); err != nil {
+				lines = append(lines, indent+"Caused by: "+firstLine(err.Error()))
+			}
+		default:
+			return
+		}
+	}
+	return
+}
+
+func firstLine(message string) string {
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		return message[:i] + " ..."
+	}
+	return message
+}
@@ -0,0 +1,59 @@
+package multilogger
+
+import (
+	"strings"
+
+	"github.com/acarl005/stripansi"
+	"github.com/sirupsen/logrus"
+)
+
+// structuredTokens is the list of standard tokens rendered by the structured modes.
+var structuredTokens = []tokenType{tokenTime, tokenLevel, tokenModule, tokenMessage, tokenDelta, tokenDelay, tokenGlobalDelay, tokenCaller, tokenVersion, tokenStack}
+
+// keyName returns the key used to render a standard token in structured modes.
+func (f *Formatter) keyName(tt tokenType) string {
+	name := strings.ToLower(tt.String())
+	if key, isSet := f.KeyNames[name]; isSet {
+		return key
+	}
+	return name
+}
+
+// structuredValues calls add for each standard token and field of the entry (in a predictable order).
+// Fields conflicting with a standard key are prefixed by fields.
+func (f *Formatter) structuredValues(entry *logrus.Entry, add func(key string, value interface{})) {
+	used := make(map[string]bool, len(structuredTokens))
+	for _, tt := range structuredTokens {
+		key := f.keyName(tt)
+		if key == "" {
+			continue
+		}
+		var value string
+		if tt == tokenStack {
+			// The error is already rendered as a field
+			value = f.formatStack(entry, false)
+		} else {
+			value = stripansi.Strip(f.tokenValue(tt, entry))
+		}
+		if value == "" && (tt == tokenModule || tt == tokenCaller || tt == tokenVersion || tt == tokenStack) {
+			continue
+		}
+		used[key] = true
+		add(key, value)
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		if !isInternalField(key) && !f.isExcluded(key, nil) {
+			keys = append(keys, key)
+		}
+	}
+	keys = f.sortFields(entry, keys, nil, false)
+	for _, key := range keys {
+		name := key
+		if used[name] {
+			name = "fields." + key
+		}
+		add(name, entry.Data[key])
+	}
+}
@@ -0,0 +1,112 @@
+package multilogger
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/acarl005/stripansi"
+	multicolor "github.com/coveooss/multilogger/color"
+	"github.com/fatih/color"
+	"github.com/sirupsen/logrus"
+)
+
+// TemplateEntry represents the data available to a template format.
+//
+// Example of template format:
+//
+//	{{ color .Entry.Level (pad -8 (upper .Level)) }} {{ .Message }}{{ if .Fields }} {{ .Fields }}{{ end }}
+//
+// The following functions are available in addition to the text/template builtin functions:
+//
+//	color attributes value:  colorize the value (attributes could be a logrus.Level or a list of color attributes such as "red,bold")
+//	pad width value:         pad the value to the specified width (negative width to align on left)
+//	truncate length value:   truncate the value to the specified length
+//	duration value:          format the duration using the formatter settings
+//	upper value:             convert the value to uppercase
+//	lower value:             convert the value to lowercase
+type TemplateEntry struct {
+	Entry       *logrus.Entry
+	Time        string
+	Level       string
+	Module      string
+	Message     string
+	Caller      string
+	Version     string
+	Stack       string // The error attached to the entry (with its causes) and the stack trace (if captured)
+	Func        string
+	File        string
+	Line        int
+	Delta       time.Duration
+	Delay       time.Duration
+	GlobalDelay time.Duration
+	Fields      logrus.Fields // The fields of the entry (excluding the module)
+}
+
+// parseTemplate compiles the template format.
+func (f *Formatter) parseTemplate(format string) (*template.Template, error) {
+	return template.New("format").Funcs(template.FuncMap{
+		"color":    f.templateColor,
+		"pad":      func(width int, value interface{}) string { return fmt.Sprintf("%*s", width, fmt.Sprint(value)) },
+		"truncate": func(length int, value interface{}) string { return fmt.Sprintf("%.*s", length, fmt.Sprint(value)) },
+		"duration": f.formatDelay,
+		"upper":    func(value interface{}) string { return strings.ToUpper(fmt.Sprint(value)) },
+		"lower":    func(value interface{}) string { return strings.ToLower(fmt.Sprint(value)) },
+	}).Parse(format)
+}
+
+func (f *Formatter) templateColor(attributes interface{}, value interface{}) (string, error) {
+	if !f.color {
+		return fmt.Sprint(value), nil
+	}
+	var colors []multicolor.Attribute
+	if level, isLevel := attributes.(logrus.Level); isLevel {
+		colors = f.ColorMap[level]
+	} else {
+		var err error
+		if colors, err = multicolor.TryConvertAttributes(attributes); err != nil {
+			return "", err
+		}
+	}
+	return color.New(colors...).Sprint(value), nil
+}
+
+func (f *Formatter) formatTemplate(entry *logrus.Entry, tmpl *template.Template) (string, error) {
+	data := TemplateEntry{
+		Entry:       entry,
+		Time:        f.tokenValue(tokenTime, entry),
+		Level:       f.tokenValue(tokenLevel, entry),
+		Message:     entry.Message,
+		Caller:      f.tokenValue(tokenCaller, entry),
+		Version:     f.tokenValue(tokenVersion, entry),
+		Stack:       f.tokenValue(tokenStack, entry),
+		Func:        f.tokenValue(tokenFunc, entry),
+		File:        f.tokenValue(tokenFile, entry),
+		Delta:       entry.Time.Sub(f.last),
+		Delay:       entry.Time.Sub(f.baseTime),
+		GlobalDelay: entry.Time.Sub(globalTime),
+		Fields:      make(logrus.Fields, len(entry.Data)),
+	}
+	if module, isSet := entry.Data[moduleFieldName]; isSet {
+		data.Module = fmt.Sprint(module)
+	}
+	if entry.Caller != nil {
+		data.Line = entry.Caller.Line
+	}
+	for key, value := range entry.Data {
+		if !isInternalField(key) && !f.isExcluded(key, nil) {
+			data.Fields[key] = value
+		}
+	}
+
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", err
+	}
+	output := builder.String()
+	if !f.color {
+		output = stripansi.Strip(output)
+	}
+	return output + "\n", nil
+}
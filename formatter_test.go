@@ -1,9 +1,17 @@
 package multilogger
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/coveooss/multilogger/errors"
 	"github.com/fatih/color"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -38,3 +46,612 @@ func TestFormatConcurrency(t *testing.T) {
 	}
 
 }
+
+func TestFormatJSON(t *testing.T) {
+	t.Parallel()
+
+	logger := New("my_module")
+	entry := &logrus.Entry{
+		Message: color.BlueString("test"),
+		Level:   logrus.ErrorLevel,
+		Time:    time.Date(2019, 12, 1, 10, 10, 11, 0, time.UTC),
+		Logger:  logger.Logger,
+		Data: map[string]interface{}{
+			moduleFieldName: "my_module",
+			"count":         3,
+			"error":         fmt.Errorf("<failure>"),
+			"level":         "custom",
+			"tags":          []string{"a", "b"},
+		},
+	}
+	noDelays := map[string]string{"delta": "", "delay": "", "globaldelay": ""}
+
+	tests := []struct {
+		name      string
+		format    string
+		configure func(*Formatter)
+		want      string
+	}{
+		{
+			name:      "NDJSON",
+			format:    "ndjson",
+			configure: func(f *Formatter) { f.KeyNames = noDelays },
+			want:      `{"time":"2019/12/01 10:10:11.000","level":"error","module":"my_module","message":"test","count":3,"error":"<failure>","fields.level":"custom","tags":["a","b"]}` + "\n",
+		},
+		{
+			name:   "JSON",
+			format: "JSON",
+			configure: func(f *Formatter) {
+				f.KeyNames = map[string]string{"delta": "", "delay": "", "globaldelay": "", "module": "", "message": "msg", "time": ""}
+			},
+			want: "{\n  \"level\": \"error\",\n  \"msg\": \"test\",\n  \"count\": 3,\n  \"error\": \"<failure>\",\n  \"fields.level\": \"custom\",\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}\n",
+		},
+		{
+			name:   "Mode overrides format",
+			format: "%message%",
+			configure: func(f *Formatter) {
+				f.Mode, f.KeyNames = NDJSONMode, noDelays
+				f.LevelName = map[logrus.Level]string{logrus.ErrorLevel: "ERR"}
+			},
+			want: `{"time":"2019/12/01 10:10:11.000","level":"ERR","module":"my_module","message":"test","count":3,"error":"<failure>","fields.level":"custom","tags":["a","b"]}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := NewFormatter(false, tt.format)
+			tt.configure(formatter)
+			result, err := formatter.Format(entry)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(result))
+		})
+	}
+}
+
+func TestFormatLogfmt(t *testing.T) {
+	t.Parallel()
+
+	logger := New("my_module")
+	entry := &logrus.Entry{
+		Message: color.BlueString("test with \"quotes\"\nand newline"),
+		Level:   logrus.WarnLevel,
+		Time:    time.Date(2019, 12, 1, 10, 10, 11, 0, time.UTC),
+		Logger:  logger.Logger,
+		Data: map[string]interface{}{
+			moduleFieldName: "my_module",
+			"count":         3,
+			"empty":         "",
+			"error":         fmt.Errorf("failure"),
+			"key with=sign": "a=b",
+			"nil":           nil,
+			"tags":          []string{"a", "b"},
+			"user":          "john",
+		},
+	}
+	formatter := NewFormatter(false, "logfmt")
+	formatter.KeyNames = map[string]string{"delta": "", "delay": "", "globaldelay": "", "message": "msg"}
+	result, err := formatter.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, `time="2019/12/01 10:10:11.000" level=warning module=my_module msg="test with \"quotes\"\nand newline" count=3 empty="" error=failure key_with_sign="a=b" nil=null tags="[\"a\",\"b\"]" user=john`+"\n", string(result))
+}
+
+func TestFormatTemplate(t *testing.T) {
+	t.Parallel()
+
+	logger := New("my_module")
+	entry := &logrus.Entry{
+		Message: "test message",
+		Level:   logrus.ErrorLevel,
+		Time:    time.Date(2019, 12, 1, 10, 10, 11, 0, time.UTC),
+		Logger:  logger.Logger,
+		Caller:  &runtime.Frame{Function: "main.main", File: "main.go", Line: 12},
+		Data:    map[string]interface{}{moduleFieldName: "my_module", "user": "john"},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		color  bool
+		want   string
+		err    string
+	}{
+		{
+			name:   "Simple",
+			format: "[{{ .Module }}] {{ pad -8 (upper .Level) }}{{ .Message }}",
+			want:   "[my_module] ERROR   test message\n",
+		},
+		{
+			name:   "Conditional",
+			format: `{{ .Message | truncate 4 }}{{ if le .Entry.Level 2 }} ({{ .File }}:{{ .Line }}){{ end }}{{ range $key, $value := .Fields }} {{ $key }}={{ $value }}{{ end }}`,
+			want:   "test (main.go:12) user=john\n",
+		},
+		{
+			name:   "Duration",
+			format: "{{ duration .Delay }}",
+			want:   "<1ms\n",
+		},
+		{
+			name:   "Color",
+			format: `{{ color .Entry.Level .Level }} {{ color "green,bold" .Message }}`,
+			color:  true,
+			want:   color.RedString("error") + " " + color.New(color.FgGreen, color.Bold).Sprint("test message") + "\n",
+		},
+		{
+			name:   "Color disabled",
+			format: `{{ color "green" .Message }}`,
+			want:   "test message\n",
+		},
+		{
+			name:   "Invalid template",
+			format: "{{ .Message ",
+			err:    "unclosed action",
+		},
+		{
+			name:   "Invalid color",
+			format: `{{ color "unknown" .Message }}`,
+			color:  true,
+			err:    "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := NewFormatter(tt.color, tt.format)
+			formatter.last = entry.Time
+			formatter.baseTime = entry.Time
+			// We test twice to ensure that the template is reused (or that the error is reported again)
+			for i := 0; i < 2; i++ {
+				result, err := formatter.Format(entry)
+				if tt.err != "" {
+					assert.ErrorContains(t, err, tt.err)
+					continue
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, string(result))
+			}
+		})
+	}
+}
+
+func TestFormatLevels(t *testing.T) {
+	t.Parallel()
+
+	logger := New("my_module")
+	newEntry := func(level logrus.Level) *logrus.Entry {
+		return &logrus.Entry{
+			Message: "test",
+			Level:   level,
+			Time:    time.Date(2019, 12, 1, 10, 10, 11, 0, time.UTC),
+			Logger:  logger.Logger,
+			Caller:  &runtime.Frame{Function: "main.main", File: "main.go", Line: 12},
+			Data:    map[string]interface{}{moduleFieldName: "my_module", "user": "john"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		setup  func(*Formatter)
+		want   map[logrus.Level]string
+		err    string
+	}{
+		{
+			name:   "Extended syntax",
+			format: "@default=%level% %message%||@error, fatal=%module% %level% %message% %caller% %fields%||@debug={{ .Level }}",
+			want: map[logrus.Level]string{
+				logrus.InfoLevel:  "info test\n",
+				logrus.ErrorLevel: "my_module error test main.main main.go:12 user=john\n",
+				logrus.FatalLevel: "my_module fatal test main.main main.go:12 user=john\n",
+				logrus.DebugLevel: "debug\n",
+			},
+		},
+		{
+			name:   "Default format last",
+			format: "@warning=%message%||@default=%level%",
+			want: map[logrus.Level]string{
+				logrus.WarnLevel: "test\n",
+				logrus.InfoLevel: "info\n",
+			},
+		},
+		{
+			name:   "No default format",
+			format: "@warning=%message%",
+			want: map[logrus.Level]string{
+				logrus.WarnLevel: "test\n",
+			},
+		},
+		{
+			name:   "Not a level format",
+			format: "%level% || %message%=@warning",
+			want: map[logrus.Level]string{
+				logrus.WarnLevel: "warning || test=@warning\n",
+			},
+		},
+		{
+			name:   "Separator within level format",
+			format: "@default=%message% || %level%||@error=%level%",
+			want: map[logrus.Level]string{
+				logrus.InfoLevel:  "test || info\n",
+				logrus.ErrorLevel: "error\n",
+			},
+		},
+		{
+			name:   "SetLevelFormat",
+			format: "%level%",
+			setup: func(f *Formatter) {
+				f.SetLevelFormat("%message%", logrus.ErrorLevel, logrus.WarnLevel)
+				f.SetLevelFormat("", logrus.WarnLevel)
+			},
+			want: map[logrus.Level]string{
+				logrus.WarnLevel:  "warning\n",
+				logrus.ErrorLevel: "test\n",
+			},
+		},
+		{
+			name:   "Invalid level",
+			format: "@default=%level%||@unknown=%message%",
+			err:    "unknown",
+		},
+		{
+			name:   "Invalid level format",
+			format: "@default=%level%||@%message%",
+			err:    "invalid level format",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := NewFormatter(false, tt.format)
+			if tt.setup != nil {
+				tt.setup(formatter)
+			}
+			if tt.err != "" {
+				_, err := formatter.Format(newEntry(logrus.InfoLevel))
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			for level, want := range tt.want {
+				result, err := formatter.Format(newEntry(level))
+				assert.NoError(t, err)
+				assert.Equal(t, want, string(result), level)
+			}
+		})
+	}
+}
+
+func TestFormatProcessTokens(t *testing.T) {
+	t.Parallel()
+
+	logger := New("my_module")
+	entry := &logrus.Entry{
+		Message: "test",
+		Level:   logrus.InfoLevel,
+		Time:    time.Date(2019, 12, 1, 10, 10, 11, 0, time.UTC),
+		Logger:  logger.Logger,
+		Data:    map[string]interface{}{moduleFieldName: "my_module", versionFieldName: "v1.0.0"},
+	}
+	hostname, _ := os.Hostname()
+	executable, _ := os.Executable()
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"%pid%", fmt.Sprint(os.Getpid())},
+		{"%hostname%", hostname},
+		{"%exe%", filepath.Base(executable)},
+		{"%.4executable%", filepath.Base(executable)[:4]},
+		{"%goroutine%", fmt.Sprint(goroutineID())},
+		{"%version:square%", "[v1.0.0]"},
+		{"%-10version%|", "v1.0.0    |"},
+		{"%fields%", "module-field=my_module"},
+	}
+	for _, tt := range tests {
+		result, err := NewFormatter(false, tt.format).Format(entry)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want+"\n", string(result), tt.format)
+	}
+
+	// The goroutine of the original caller is kept when the entry is processed by another goroutine
+	entry.Data[goroutineFieldName] = uint64(123456)
+	result, _ := NewFormatter(false, "%goroutine%").Format(entry)
+	assert.Equal(t, "123456\n", string(result))
+}
+
+func TestFormatUsesToken(t *testing.T) {
+	t.Parallel()
+
+	formatter := NewFormatter(false, "@default=%message%||@debug=%goroutine% %message%")
+	assert.False(t, formatter.usesToken(logrus.InfoLevel, tokenGoroutine))
+	assert.True(t, formatter.usesToken(logrus.DebugLevel, tokenGoroutine))
+
+	// The format errors are still reported when the entry is formatted
+	formatter = NewFormatter(false, "@default=%goroutine%||@unknown=%message%")
+	assert.True(t, formatter.usesToken(logrus.InfoLevel, tokenGoroutine))
+	_, err := formatter.Format(&logrus.Entry{Level: logrus.InfoLevel})
+	assert.ErrorContains(t, err, "unknown")
+}
+
+func TestFormatMultiline(t *testing.T) {
+	t.Parallel()
+
+	logger := New("my_module")
+	entry := &logrus.Entry{
+		Message: "first line\nsecond line\n\nlast line",
+		Level:   logrus.InfoLevel,
+		Time:    time.Date(2019, 12, 1, 10, 10, 11, 0, time.UTC),
+		Logger:  logger.Logger,
+		Data:    map[string]interface{}{moduleFieldName: "my_module"},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		color  bool
+		want   string
+	}{
+		{
+			name:   "Default",
+			format: "[%module%] %-8level:upper% %message%",
+			want:   "[my_module] INFO     first line\nsecond line\n\nlast line\n",
+		},
+		{
+			name:   "Indent",
+			format: "[%module%] %-8level:upper% %message:indent% (end)",
+			want:   "[my_module] INFO     first line\n                     second line\n\n                     last line (end)\n",
+		},
+		{
+			name:   "Repeat",
+			format: "[%module%] %-8level:upper% %message:repeat%",
+			want:   "[my_module] INFO     first line\n[my_module] INFO     second line\n[my_module] INFO     \n[my_module] INFO     last line\n",
+		},
+		{
+			name:   "Indent with colors",
+			format: "%level:upper,color% %message:indent,blue%",
+			color:  true,
+			want: color.New(color.FgBlue, color.Bold).Sprint("INFO") + " " +
+				color.BlueString("first line") + "\n     " + color.BlueString("second line") + "\n\n     " + color.BlueString("last line") + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewFormatter(tt.color, tt.format).Format(entry)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(result))
+		})
+	}
+}
+
+func TestFormatCaller(t *testing.T) {
+	t.Parallel()
+
+	_, file, _, _ := runtime.Caller(0)
+	logger := New("my_module")
+	newEntry := func(file string) *logrus.Entry {
+		return &logrus.Entry{
+			Message: "test",
+			Level:   logrus.InfoLevel,
+			Time:    time.Date(2019, 12, 1, 10, 10, 11, 0, time.UTC),
+			Logger:  logger.Logger,
+			Caller:  &runtime.Frame{Function: "github.com/coveooss/multilogger.(*Logger).Info", File: file, Line: 12},
+			Data:    map[string]interface{}{moduleFieldName: "my_module"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		file   string
+		setup  func(*Formatter)
+		want   string
+	}{
+		{"Default", "%caller%", "/src/project/main.go", nil, "github.com/coveooss/multilogger.(*Logger).Info /src/project/main.go:12"},
+		{"Base name", "%file%", "/src/project/main.go", func(f *Formatter) { f.CallerPath = BaseName }, "main.go"},
+		{"Base name attribute", "%file:base%:%line%", "/src/project/main.go", nil, "main.go:12"},
+		{"Short function", "%func%", "", func(f *Formatter) { f.ShortFunctions = true }, "multilogger.Logger.Info"},
+		{"Short attribute", "%caller:short,basename%", "/src/project/main.go", nil, "multilogger.Logger.Info main.go:12"},
+		{"Module root", "%file:relative%", file, nil, "formatter_test.go"},
+		{"Prefix", "%caller%", "/src/project/cmd/main.go", func(f *Formatter) {
+			f.CallerPath, f.CallerPrefixes = RelativePath, []string{"/other", "/src/project/"}
+		}, "github.com/coveooss/multilogger.(*Logger).Info cmd/main.go:12"},
+		{"Attribute overrides settings", "%file:full%", "/src/project/main.go", func(f *Formatter) { f.CallerPath = BaseName }, "/src/project/main.go"},
+		{"Relative path unchanged", "%file:relative%", "github.com/org/project/main.go", nil, "github.com/org/project/main.go"},
+		{"Custom FormatCaller", "%caller% %caller:base%", "/src/project/main.go", func(f *Formatter) {
+			f.FormatCaller = func(frame *runtime.Frame) string { return "custom" }
+		}, "custom github.com/coveooss/multilogger.(*Logger).Info main.go:12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := NewFormatter(false, tt.format)
+			if tt.setup != nil {
+				tt.setup(formatter)
+			}
+			result, err := formatter.Format(newEntry(tt.file))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want+"\n", string(result))
+		})
+	}
+}
+
+func TestFormatStack(t *testing.T) {
+	var buffer bytes.Buffer
+	hook := NewConsoleHook("", logrus.InfoLevel).SetColor(false).SetOut(&buffer)
+	hook.SetFormat("%level% %message%%stack%")
+	hook.Formatter().ShortFunctions = true
+	hook.Formatter().CallerPath = BaseName
+	logger := New("stack", hook)
+
+	// The stack trace is not captured by default
+	logger.Error("Error")
+	assert.Equal(t, "error Error\n", buffer.String())
+
+	// The stack trace is captured for errors and above
+	buffer.Reset()
+	logger.SetStackTraceLevel(logrus.ErrorLevel)
+	inner := errors.Array{fmt.Errorf("first\nmultiline"), fmt.Errorf("second")}
+	logger.WithError(fmt.Errorf("outer: %w", inner)).Error("Failure")
+	lines := strings.Split(buffer.String(), "\n")
+	assert.Equal(t, []string{
+		"error Failure",
+		"Error: outer: first ...",
+		"Caused by: first ...",
+		"  Error: first ...",
+		"  Error: second",
+		"Stack trace:",
+		"  multilogger.TestFormatStack",
+	}, lines[:7])
+	assert.Regexp(t, `^      formatter_test.go:\d+$`, lines[7])
+
+	// There is no stack trace for less severe levels
+	buffer.Reset()
+	logger.WithError(fmt.Errorf("not captured")).Warning("Warning")
+	assert.Equal(t, "warning Warning\nError: not captured\n", buffer.String())
+
+	// The capture level can be changed
+	buffer.Reset()
+	logger.SetStackTraceLevel(DisabledLevel)
+	logger.Error("Error")
+	assert.Equal(t, "error Error\n", buffer.String())
+	buffer.Reset()
+	logger.SetStackTraceLevel(logrus.InfoLevel)
+	logger.Info("Info")
+	assert.Contains(t, buffer.String(), "info Info\nStack trace:\n  multilogger.TestFormatStack\n")
+
+	// The nil errors wrapped by a multi-error are ignored
+	buffer.Reset()
+	logger.SetStackTraceLevel(DisabledLevel)
+	logger.WithError(multiError{fmt.Errorf("first"), nil}).Error("Failure")
+	assert.Equal(t, "error Failure\nError: multiple errors\n  Error: first\n", buffer.String())
+}
+
+// multiError is an error that wraps several errors without being an errors.Array.
+type multiError []error
+
+func (e multiError) Error() string   { return "multiple errors" }
+func (e multiError) Unwrap() []error { return e }
+
+func TestFormatFieldValues(t *testing.T) {
+	t.Parallel()
+
+	type point struct{ X, Y int }
+	logger := New("my_module")
+	entry := &logrus.Entry{
+		Message: "test",
+		Level:   logrus.InfoLevel,
+		Time:    time.Date(2019, 12, 1, 10, 10, 11, 0, time.UTC),
+		Logger:  logger.Logger,
+		Data: map[string]interface{}{
+			moduleFieldName: "my_module",
+			"elapsed":       1500 * time.Millisecond,
+			"point":         point{1, 2},
+			"size":          1536,
+			"start":         time.Date(2019, 12, 1, 10, 10, 0, 0, time.UTC),
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		setup  func(*Formatter)
+		want   string
+	}{
+		{"Default", "%fields%", nil, "elapsed=1.5s module-field=my_module point={1 2} size=1536 start=2019-12-01 10:10:00 +0000 UTC"},
+		{"Attributes", "%point:json% %size:bytes% %start:time% %elapsed:duration%", nil, `{"X":1,"Y":2} 1.5 KiB 2019/12/01 10:10:00.000 1.5s`},
+		{"Fields attribute", "%module% %fields:json%", nil, `my_module elapsed=1500000000 point={"X":1,"Y":2} size=1536 start="2019-12-01T10:10:00Z"`},
+		{"Key formatters", "%module% %fields% %-10size%|", func(f *Formatter) {
+			f.FieldFormatters = map[string]FieldFormatter{"size": BytesField, "point": JSONField}
+		}, `my_module elapsed=1.5s point={"X":1,"Y":2} start=2019-12-01 10:10:00 +0000 UTC 1.5 KiB   |`},
+		{"Type formatters", "%module% %fields%", func(f *Formatter) {
+			f.FieldFormatters = map[string]FieldFormatter{"elapsed": SprintField}
+			f.TypeFormatters = map[reflect.Type]FieldFormatter{
+				reflect.TypeOf(time.Duration(0)): func(*Formatter, interface{}) string { return "custom" },
+				reflect.TypeOf(time.Time{}):      TimeField,
+			}
+		}, "my_module elapsed=1.5s point={1 2} size=1536 start=2019/12/01 10:10:00.000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := NewFormatter(false, tt.format)
+			if tt.setup != nil {
+				tt.setup(formatter)
+			}
+			result, err := formatter.Format(entry)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want+"\n", string(result))
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+
+	for size, want := range map[int64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1024:          "1 KiB",
+		1536:          "1.5 KiB",
+		-2048:         "-2 KiB",
+		5 << 20:       "5 MiB",
+		3 << 40:       "3 TiB",
+		1<<63 - 1:     "8 EiB",
+		1<<30 + 1<<29: "1.5 GiB",
+	} {
+		assert.Equal(t, want, FormatBytes(size), size)
+	}
+}
+
+func TestFormatFieldOrder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		format string
+		setup  func(*Formatter)
+		want   string
+	}{
+		{"Default", "%fields%", nil, "a=1 module-field=order password=secret request_id=42 user=john z=0"},
+		{"Priority", "%module% %fields%", func(f *Formatter) { f.FieldOrder = []string{"user", "request_id", "unknown"} }, "order user=john request_id=42 a=1 password=secret z=0"},
+		{"Exclusions", "%module% %fields%", func(f *Formatter) { f.ExcludedFields = []string{"password"} }, "order a=1 request_id=42 user=john z=0"},
+		{"Insertion", "%module% %fields%", func(f *Formatter) { f.InsertionOrder = true }, "order z=0 user=john password=secret request_id=42 a=1"},
+		{"Insertion after priority", "%module% %fields%", func(f *Formatter) {
+			f.InsertionOrder, f.FieldOrder = true, []string{"a"}
+		}, "order a=1 z=0 user=john password=secret request_id=42"},
+		{"Attributes", "%module% %fields:order(request_id user),exclude(password a)%", nil, "order request_id=42 user=john z=0"},
+		{"Insertion attribute", "%module% %fields:Insertion,exclude(password)%", nil, "order z=0 user=john request_id=42 a=1"},
+		{"JSON", "ndjson", func(f *Formatter) {
+			f.ExcludedFields, f.FieldOrder = []string{"password"}, []string{"user"}
+			f.KeyNames = map[string]string{"time": "", "delta": "", "delay": "", "globaldelay": ""}
+		}, `{"level":"info","module":"order","message":"test","user":"john","a":1,"request_id":42,"z":0}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			hook := NewConsoleHook("", logrus.InfoLevel).SetColor(false).SetOut(&buffer)
+			hook.SetFormat(tt.format)
+			if tt.setup != nil {
+				tt.setup(hook.Formatter())
+			}
+			logger := New("order", hook).WithField("z", 0).WithField("user", "john")
+			logger = logger.WithFields(logrus.Fields{"request_id": 42, "password": "secret"}).WithField("a", 1)
+			logger.Info("test")
+			assert.Equal(t, tt.want+"\n", buffer.String())
+		})
+	}
+}
+
+func TestFormatInsertionOrderTracking(t *testing.T) {
+	t.Parallel()
+
+	hook := NewConsoleHook("", logrus.InfoLevel, "%fields%")
+	logger := New("order", hook)
+
+	// The insertion order is only tracked if a formatter requires it
+	assert.NotContains(t, logger.WithField("a", 1).Data, orderFieldName)
+	hook.SetFormat("@default=%fields%||@debug=%fields:insertion%")
+	assert.Equal(t, []string{"a"}, logger.WithField("a", 1).Data[orderFieldName])
+	hook.SetFormat("%fields%")
+	hook.Formatter().InsertionOrder = true
+	assert.Equal(t, []string{"a", "b"}, logger.WithField("a", 1).WithField("b", 2).Data[orderFieldName])
+
+	// Adding fields does not wait for the formatters that are formatting entries
+	hook.Formatter().replacerLock.Lock()
+	defer hook.Formatter().replacerLock.Unlock()
+	assert.Equal(t, []string{"a"}, logger.WithField("a", 1).Data[orderFieldName])
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync/atomic"
 
 	"github.com/coveooss/multilogger/errors"
 	"github.com/sirupsen/logrus"
@@ -20,17 +21,22 @@ type genericHookI interface {
 
 type genericHook struct {
 	formatter logrus.Formatter
-	logger    *Logger
+	logger    atomic.Pointer[Logger]
 }
 
-func (hook genericHook) clone() *genericHook {
-	return &hook
+func (hook *genericHook) clone() *genericHook {
+	result := &genericHook{formatter: hook.formatter}
+	result.logger.Store(hook.getLogger())
+	return result
 }
 
 func (hook *genericHook) formatEntry(name string, entry *logrus.Entry) (string, error) {
 	if hook.formatter == nil {
 		hook.formatter = NewFormatter(true, os.Getenv(FormatFileEnvVar), os.Getenv(FormatEnvVar), DefaultFileFormat)
 	}
+	if _, isFormatter := hook.formatter.(*Formatter); !isFormatter {
+		entry = withoutHiddenFields(entry)
+	}
 	formatted, err := hook.formatter.Format(entry)
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", name, err)
@@ -54,10 +60,10 @@ func (hook *genericHook) printf(source string, out io.Writer, format string, arg
 func (hook *genericHook) fire(entry *logrus.Entry, f func(entry *logrus.Entry) error) (err error) {
 	defer func() {
 		err = errors.Trap(err, recover())
-		if hook.logger != nil && err != nil {
+		if logger := hook.getLogger(); logger != nil && err != nil {
 			// We report the error to the logger since the fire mechanism does not
 			// handle errors very well
-			hook.logger.AddError(err)
+			logger.AddError(err)
 		}
 	}()
 
@@ -68,4 +74,5 @@ func (hook *genericHook) Levels() []logrus.Level                  { return nil }
 func (hook *genericHook) Fire(entry *logrus.Entry) error          { return fmt.Errorf("not implemented") }
 func (hook *genericHook) SetFormatter(formatter logrus.Formatter) { hook.formatter = formatter }
 func (hook *genericHook) Formatter() logrus.Formatter             { return hook.formatter }
-func (hook *genericHook) SetLogger(l *Logger)                     { hook.logger = l }
+func (hook *genericHook) SetLogger(l *Logger)                     { hook.logger.Store(l) }
+func (hook *genericHook) getLogger() *Logger                      { return hook.logger.Load() }
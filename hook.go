@@ -12,6 +12,14 @@ type cloneable interface {
 	clone() logrus.Hook
 }
 
+type reopener interface {
+	Reopen() error
+}
+
+type flusher interface {
+	Flush() error
+}
+
 // NewHook generates a named hook wrapper that is able the handle its own logging level.
 //
 // level: Accept any kind of object, but must be resolvable into a valid logrus level name.
@@ -40,15 +48,20 @@ func NewConsoleHook(name string, level interface{}, format ...interface{}) *Hook
 // NewFileHook creates a new hook to log information into a file.
 //
 // level: Accept any kind of object, but must be resolvable into a valid logrus level name.
+//
+// format: Any FileOption (i.e. FileMaxSize, FileMaxBackups) supplied in the format arguments is used to configure
+// the file hook, the remaining arguments are used to configure the formatter.
+// By default, files are created with 0644 permissions and directories with 0755 (see FilePerm, FileDirPerm and FileLegacyPermissions).
 func NewFileHook(filename string, isDir bool, level interface{}, format ...interface{}) *Hook {
-	if format == nil {
+	options, format := extractFileOptions(format)
+	if len(format) == 0 {
 		format = append(format, NewFormatter(false, os.Getenv(FormatFileEnvVar), os.Getenv(FormatEnvVar), DefaultFileFormat))
 	}
 	return NewHook(filename, level, &fileHook{
 		genericHook: &genericHook{formatter: getFormatter(false, format...)},
+		fileOptions: options,
 		isDir:       isDir,
 		filename:    filename,
-		addHeader:   true,
 	})
 }
 
@@ -66,9 +79,10 @@ func getFormatter(color bool, format ...interface{}) (formatter logrus.Formatter
 
 // Hook represents a hook that logs at a given level. This struct must be extended to implement the Fire func.
 type Hook struct {
-	name  string
-	inner logrus.Hook
-	level logrus.Level
+	name        string
+	inner       logrus.Hook
+	level       logrus.Level
+	noRedaction bool
 }
 
 // Levels returns the levels that should be handled by the hook.
@@ -112,9 +126,47 @@ func (hook *Hook) Fire(entry *logrus.Entry) error {
 	if hook.inner == nil {
 		return fmt.Errorf("Hook not configured properly")
 	}
+	if redacted, isRedacted := entry.Data[redactedFieldName].(*logrus.Entry); isRedacted && !hook.noRedaction {
+		// The entry has been redacted by the redaction hook of the logger
+		entry = redacted
+	}
+	if _, isGeneric := hook.inner.(genericHookI); !isGeneric {
+		// Generic hooks remove the hidden fields themselves if they do not use a Formatter
+		entry = withoutHiddenFields(entry)
+	}
 	return hook.inner.Fire(entry)
 }
 
+// DisableRedaction allows the hook to receive the entries without the secrets being masked by the
+// redactor of the logger (i.e. for a secured audit file).
+func (hook *Hook) DisableRedaction() *Hook {
+	hook.noRedaction = true
+	if async, isAsync := hook.inner.(*asyncHook); isAsync {
+		async.inner.DisableRedaction()
+	}
+	return hook
+}
+
+// hookFormatter returns the Formatter used by the hook (or by the hook wrapped by an asynchronous hook).
+func hookFormatter(hook logrus.Hook) *Formatter {
+	switch hook := hook.(type) {
+	case *Hook:
+		return hookFormatter(hook.inner)
+	case *asyncHook:
+		return hookFormatter(hook.inner)
+	case genericHookI:
+		f, _ := hook.Formatter().(*Formatter)
+		return f
+	}
+	return nil
+}
+
+// usesToken indicates if the hook formats the entries of the level with a Formatter that uses the token.
+func usesToken(hook logrus.Hook, level logrus.Level, tt tokenType) bool {
+	f := hookFormatter(hook)
+	return f != nil && f.usesToken(level, tt)
+}
+
 // GetFormatter returns the formater associated to the hook.
 // The function will panic if called upon a hook that do not support formatter.
 func (hook *Hook) GetFormatter() logrus.Formatter {
@@ -169,6 +221,37 @@ func (hook *Hook) SetStdout(out io.Writer) *Hook {
 	return hook
 }
 
+// Reopen forces the hook to reopen its target on the next write if it supports it (i.e. file hooks).
+// This is useful when an external process like logrotate moves the log files.
+func (hook *Hook) Reopen() error {
+	if r, ok := hook.inner.(reopener); ok {
+		return r.Reopen()
+	}
+	return nil
+}
+
+// Flush flushes the pending entries of the hook if it supports it.
+func (hook *Hook) Flush() error {
+	if f, ok := hook.inner.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close closes the hook if it implements io.Closer.
+func (hook *Hook) Close() error {
+	if c, ok := hook.inner.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Dropped returns the number of entries that have been dropped by an asynchronous hook because its queue was full.
+// The function will panic if called upon a hook that is not an asynchronous hook.
+func (hook *Hook) Dropped() uint64 {
+	return hook.inner.(asyncI).Dropped()
+}
+
 // GetInnerHook returns the inner hook actually used by the leveled hook.
 func (hook *Hook) GetInnerHook() logrus.Hook {
 	return hook.inner
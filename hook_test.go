@@ -1,9 +1,15 @@
 package multilogger
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,3 +36,193 @@ func TestGetLoggingLevels(t *testing.T) {
 		})
 	}
 }
+
+type lifecycleHook struct {
+	genericHook
+	fired, flushed, closed int
+}
+
+func (hook *lifecycleHook) Fire(*logrus.Entry) error { hook.fired++; return nil }
+func (hook *lifecycleHook) Flush() error             { hook.flushed++; return nil }
+func (hook *lifecycleHook) Close() error             { hook.closed++; return fmt.Errorf("close error") }
+
+func TestLogger_FlushAndClose(t *testing.T) {
+	hook := &lifecycleHook{}
+	log := getTestLogger("lifecycle").AddHook("lifecycle", logrus.InfoLevel, hook)
+
+	log.Info("Hello")
+	assert.NoError(t, log.Flush())
+	assert.Equal(t, 1, hook.flushed)
+	assert.EqualError(t, log.Close(), "close error")
+	assert.Equal(t, 1, hook.closed)
+
+	log.RemoveHook("lifecycle")
+	assert.Equal(t, 2, hook.closed)
+	assert.EqualError(t, log.ClearError(), "close error")
+	assert.Equal(t, []string{consoleHookName}, log.ListHooks())
+}
+
+func TestLogger_FatalFlushesHooks(t *testing.T) {
+	hook := &lifecycleHook{}
+	log := getTestLogger("lifecycle").AddHook("lifecycle", logrus.InfoLevel, hook)
+	log.SetOut(io.Discard)
+
+	var exitCode, flushedBeforeExit int
+	log.SetExitFunc(func(code int) { exitCode, flushedBeforeExit = code, hook.flushed })
+	log.Fatal("Fatal error")
+	assert.Equal(t, 1, exitCode)
+	assert.Equal(t, 1, hook.fired)
+	assert.Equal(t, 1, flushedBeforeExit)
+}
+
+// syncBuffer is a buffer that can be written by many goroutines.
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) Lines() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return strings.Count(b.buffer.String(), "\n")
+}
+
+func TestLogger_ConcurrentHooks(t *testing.T) {
+	t.Parallel()
+
+	var out syncBuffer
+	log := New("concurrent", NewConsoleHook("", logrus.InfoLevel).SetColor(false).SetOut(&out))
+	log.AddHooks(NewConsoleHook("other", logrus.InfoLevel).SetOut(io.Discard))
+	child := log.Child("child")
+
+	const count = 100
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			log.Infof("message %d", i)
+			child.Warningf("child %d", i)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		slog := log.Slog()
+		for i := 0; i < count; i++ {
+			slog.Warn("slog")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			log.AddHooks(NewConsoleHook(fmt.Sprintf("hook %d", i%5), logrus.InfoLevel).SetOut(io.Discard))
+			assert.NoError(t, log.SetHookLevel("other", logrus.TraceLevel-logrus.Level(i%2)))
+			log.SetStackTraceLevel(logrus.Level(i % 3))
+			log.RemoveHook(fmt.Sprintf("hook %d", (i+2)%5))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			log.GetLevel()
+			log.ListHooks()
+			log.SetRedactor(NewRedactor())
+			log.Copy("copy").Debug("copy")
+			assert.NoError(t, log.Flush())
+		}
+	}()
+	wg.Wait()
+
+	assert.Contains(t, log.ListHooks(), consoleHookName)
+	assert.Equal(t, 3*count, out.Lines())
+}
+
+func TestLogger_ConcurrentErrors(t *testing.T) {
+	t.Parallel()
+
+	// The errors are reported in background by the asynchronous hook
+	hook := NewConsoleHook("", logrus.InfoLevel).SetOut(&buggyWriter{fmt.Errorf("Disk is full")})
+	log := New("errors", NewAsyncHook(hook, AsyncOptions{}))
+
+	const count = 50
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			log.Info("message")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			log.AddError(fmt.Errorf("error %d", i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			log.GetError()
+			if i%10 == 0 {
+				log.ClearError()
+			}
+		}
+	}()
+	wg.Wait()
+
+	log.Info("last message")
+	assert.NoError(t, log.Flush())
+	assert.ErrorContains(t, log.ClearError(), "Disk is full")
+	assert.NoError(t, log.GetError())
+}
+
+func TestLogger_SharedHooks(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	log := New("shared", NewConsoleHook("", logrus.InfoLevel).SetColor(false).SetOut(&out))
+	log.SetFormat("%module% %message% %fields%")
+	derived := log.WithField("key", "value").Child("child")
+	copied := log.Copy("copy")
+
+	// Hooks added to a derived logger are shared with its parent, but not with the copies
+	derived.AddHooks(NewHook("lifecycle", logrus.InfoLevel, &lifecycleHook{}))
+	assert.Equal(t, []string{consoleHookName, "lifecycle"}, log.ListHooks())
+	assert.Equal(t, []string{consoleHookName}, copied.ListHooks())
+
+	log.Info("parent")
+	derived.Info("derived")
+	assert.Equal(t, "shared parent \nshared:child derived key=value\n", out.String())
+
+	// The errors are also shared
+	derived.AddError(fmt.Errorf("derived error"))
+	assert.EqualError(t, log.GetError(), "derived error")
+	assert.NoError(t, copied.GetError())
+	assert.Error(t, derived.Close())
+	assert.Equal(t, 1, log.Hook("lifecycle").inner.(*lifecycleHook).closed)
+}
+
+func TestHook_HiddenFields(t *testing.T) {
+	var out, asyncOut bytes.Buffer
+	raw := test.NewLocal(logrus.New())
+	log := New("hidden", NewConsoleHook("json", logrus.InfoLevel, &logrus.JSONFormatter{DisableTimestamp: true}).SetOut(&out))
+	log.AddHooks(NewAsyncHook(NewConsoleHook("async", logrus.InfoLevel, &logrus.JSONFormatter{DisableTimestamp: true}).SetOut(&asyncOut), AsyncOptions{}))
+	log.AddHook("raw", logrus.InfoLevel, raw)
+	log.SetStackTraceLevel(logrus.InfoLevel)
+	log = log.SetVersion("v1.2.3").WithField("key", "value")
+
+	log.Info("Hello")
+	assert.NoError(t, log.Flush())
+
+	// The hidden fields are not sent to hooks and formatters that are not aware of them
+	want := `{"key":"value","level":"info","module-field":"hidden","msg":"Hello"}` + "\n"
+	assert.Equal(t, want, out.String())
+	assert.Equal(t, want, asyncOut.String())
+	assert.Equal(t, logrus.Fields{"key": "value", moduleFieldName: "hidden"}, raw.LastEntry().Data)
+}
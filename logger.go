@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
+	"os/signal"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/coveooss/multilogger/errors"
@@ -15,18 +19,72 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const moduleFieldName = "module-field"
+const (
+	moduleFieldName    = "module-field"
+	versionFieldName   = "version-field"   // Hidden field used to store the version of the application
+	goroutineFieldName = "goroutine-field" // Hidden field used to store the goroutine that logged an entry processed asynchronously
+	stackFieldName     = "stack-field"     // Hidden field used to store the stack trace captured when an entry is logged
+	orderFieldName     = "order-field"     // Hidden field used to store the insertion order of the fields
+	redactedFieldName  = "redacted-field"  // Hidden field used to store the redacted copy of an entry
+)
+
+// hiddenFields contains the internal fields that are never rendered as regular fields.
+var hiddenFields = []string{versionFieldName, goroutineFieldName, stackFieldName, orderFieldName, redactedFieldName}
+
+// withoutHiddenFields returns a copy of the entry without the hidden fields if it contains any. It is used
+// to send the entries to hooks and formatters that are not aware of them.
+func withoutHiddenFields(entry *logrus.Entry) *logrus.Entry {
+	for _, hidden := range hiddenFields {
+		if _, isSet := entry.Data[hidden]; isSet {
+			entry = copyEntry(entry)
+			for _, hidden := range hiddenFields {
+				delete(entry.Data, hidden)
+			}
+			return entry
+		}
+	}
+	return entry
+}
+
+func isInternalField(key string) bool {
+	if key == moduleFieldName {
+		return true
+	}
+	for _, hidden := range hiddenFields {
+		if key == hidden {
+			return true
+		}
+	}
+	return false
+}
 
 // Logger represents a logger that logs to both a file and the console at different (configurable) levels.
+//
+// The loggers derived with WithField, WithFields, WithTime, WithContext and Child share the hooks and the
+// error state of their parent, so modifying the hooks of a derived logger also affects its parent. Use Copy
+// to get a logger with its own hooks.
 type Logger struct {
 	*logrus.Entry
+	*loggerState
 	PrintLevel logrus.Level
 	Catcher    bool
 
-	hooks     map[string]*leveledHook
-	level     logrus.Level
-	remaining string
-	errors    errors.Array // Used to cumultate errors in the logging process
+	remaining    string
+	catcherMutex sync.Mutex // The catcher could be written by many goroutines
+}
+
+// loggerState holds the hooks and the errors shared by a logger and the loggers derived from it.
+type loggerState struct {
+	hooks      map[string]*leveledHook
+	levelHooks logrus.LevelHooks // Hooks registered in the logrus logger, never modified once registered
+	level      logrus.Level
+	errors     errors.Array // Used to cumultate errors in the logging process
+	exitFunc   func(int)
+	stackLevel logrus.Level
+	redactor   *Redactor
+
+	hooksMutex  sync.RWMutex // Hooks could be modified while other goroutines are logging
+	errorsMutex sync.Mutex   // Errors could be reported by hooks running in background
 }
 
 type leveledHook struct {
@@ -49,15 +107,17 @@ func New(module string, hooks ...*Hook) *Logger {
 		hooks = []*Hook{NewConsoleHook("", logrus.WarnLevel)}
 	}
 	logger := &Logger{
-		Entry:   createInnerLogger(ParseBool(os.Getenv(CallerEnvVar)), logrus.Fields{moduleFieldName: module}),
-		Catcher: true,
+		Entry:       createInnerLogger(ParseBool(os.Getenv(CallerEnvVar)), logrus.Fields{moduleFieldName: module}),
+		loggerState: &loggerState{stackLevel: DisabledLevel},
+		Catcher:     true,
 	}
+	logger.Logger.ExitFunc = logger.exit
 	logger.AddHooks(hooks...)
 	logger.PrintLevel = outputLevel
 	return logger
 }
 
-// Copy returns a new logger with the same hooks but a different module name.
+// Copy returns a new logger with a copy of the hooks (and of the error state) but a different module name.
 // module is optional, if not supplied, the original module name will copied.
 // If many name are supplied, they are joined with a - separator.
 func (logger *Logger) Copy(module ...string) *Logger {
@@ -67,6 +127,7 @@ func (logger *Logger) Copy(module ...string) *Logger {
 		moduleName = logger.GetModule()
 	}
 
+	logger.hooksMutex.RLock()
 	var hooks []*Hook
 	for key, hook := range logger.hooks {
 		inner := hook.hook.inner
@@ -74,32 +135,55 @@ func (logger *Logger) Copy(module ...string) *Logger {
 			// We duplicate the inner hook if it is cloneable
 			inner = cloneable.clone()
 		}
-		hooks = append(hooks, NewHook(key, hook.level, inner))
+		newHook := NewHook(key, hook.level, inner)
+		newHook.noRedaction = hook.hook.noRedaction
+		hooks = append(hooks, newHook)
 	}
-
-	return (&Logger{
-		Entry:      createInnerLogger(logger.Logger.ReportCaller, logger.Entry.Data).WithTime(logger.Time).WithContext(logger.Context).WithField(moduleFieldName, moduleName),
+	level, stackLevel, redactor, exitFunc := logger.level, logger.stackLevel, logger.redactor, logger.exitFunc
+	logger.hooksMutex.RUnlock()
+
+	logger.catcherMutex.Lock()
+	remaining := logger.remaining
+	logger.catcherMutex.Unlock()
+
+	newLogger := &Logger{
+		Entry: createInnerLogger(logger.Logger.ReportCaller, logger.Entry.Data).WithTime(logger.Time).WithContext(logger.Context).WithField(moduleFieldName, moduleName),
+		loggerState: &loggerState{
+			level:      level,
+			errors:     logger.getErrors(),
+			exitFunc:   exitFunc,
+			stackLevel: stackLevel,
+			redactor:   redactor,
+		},
 		PrintLevel: logger.PrintLevel,
 		Catcher:    logger.Catcher,
-		level:      logger.level,
-		remaining:  logger.remaining,
-		errors:     logger.errors,
-	}).AddHooks(hooks...)
+		remaining:  remaining,
+	}
+	newLogger.Logger.ExitFunc = newLogger.exit
+	return newLogger.AddHooks(hooks...)
 }
 
-// Child clones the logger, appending the child's name to the parent's module name.
+// Child returns a logger sharing the hooks of the current logger, appending the child's name to the parent's module name.
 func (logger *Logger) Child(child string) *Logger {
 	if module := logger.GetModule(); module != "" {
-		return logger.Copy(fmt.Sprintf("%s:%s", module, child))
+		child = fmt.Sprintf("%s:%s", module, child)
+	}
+	return logger.derive(logger.Entry.WithField(moduleFieldName, child))
+}
+
+// derive returns a new logger using the supplied entry that shares the hooks and the error state of the current logger.
+func (logger *Logger) derive(entry *logrus.Entry) *Logger {
+	return &Logger{
+		Entry:       entry,
+		loggerState: logger.loggerState,
+		PrintLevel:  logger.PrintLevel,
+		Catcher:     logger.Catcher,
 	}
-	return logger.Copy(child)
 }
 
 // WithTime return a new logger with a fixed time for log entry (useful for testing).
 func (logger *Logger) WithTime(time time.Time) *Logger {
-	newLogger := logger.Copy(logger.GetModule())
-	newLogger.Entry = newLogger.Entry.WithTime(time)
-	return newLogger
+	return logger.derive(logger.Entry.WithTime(time))
 }
 
 // AddTime add the specified duration to the current logger if its time has been freezed. Useful for testing.
@@ -117,16 +201,48 @@ func (logger *Logger) WithField(key string, value interface{}) *Logger {
 
 // WithFields return a new logger with a new fields value.
 func (logger *Logger) WithFields(fields logrus.Fields) *Logger {
-	newLogger := logger.Copy(logger.GetModule())
-	newLogger.Entry = logger.Entry.WithFields(fields)
-	return newLogger
+	if !logger.tracksInsertionOrder() {
+		return logger.derive(logger.Entry.WithFields(fields))
+	}
+	// The insertion order is added to the new fields to avoid copying the entry twice
+	data := make(logrus.Fields, len(fields)+1)
+	for key, value := range fields {
+		data[key] = value
+	}
+	data[orderFieldName] = fieldsOrder(logger.Data, fields)
+	return logger.derive(logger.Entry.WithFields(data))
+}
+
+// tracksInsertionOrder indicates if a hook renders the fields in the order they have been added.
+func (logger *Logger) tracksInsertionOrder() bool {
+	logger.hooksMutex.RLock()
+	defer logger.hooksMutex.RUnlock()
+	for _, hook := range logger.hooks {
+		if f := hookFormatter(hook.hook); f != nil && f.usesInsertionOrder() {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldsOrder returns the insertion order of the fields after adding the new fields to the existing ones.
+// The order of the new fields is alphabetical since it is not preserved by the map.
+func fieldsOrder(existing, fields logrus.Fields) []string {
+	previous, _ := existing[orderFieldName].([]string)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if _, isSet := existing[key]; !isSet && !isInternalField(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	// We do not modify the previous order since it is shared with the parent logger
+	return append(append(make([]string, 0, len(previous)+len(keys)), previous...), keys...)
 }
 
 // WithContext return a new logger with a new context.
 func (logger *Logger) WithContext(ctx context.Context) *Logger {
-	newLogger := logger.Copy(logger.GetModule())
-	newLogger.Entry = logger.Entry.WithContext(ctx)
-	return newLogger
+	return logger.derive(logger.Entry.WithContext(ctx))
 }
 
 // Print acts as fmt.Print but sends the output to a special logging level that allows multiple output support through Hooks.
@@ -152,6 +268,8 @@ func (logger *Logger) Printf(format string, args ...interface{}) {
 
 // GetLevel returns the highest logger level registered by the hooks.
 func (logger *Logger) GetLevel() logrus.Level {
+	logger.hooksMutex.RLock()
+	defer logger.hooksMutex.RUnlock()
 	if mainLevel := logger.Logger.GetLevel(); mainLevel < logger.level {
 		return mainLevel
 	}
@@ -169,6 +287,46 @@ func (logger *Logger) SetModule(module string) *Logger {
 	return logger
 }
 
+// GetVersion returns the application version associated to the current logger.
+func (logger *Logger) GetVersion() string {
+	version, _ := logger.Data[versionFieldName].(string)
+	return version
+}
+
+// SetVersion sets the application version associated to the current logger (available through the %version% token).
+func (logger *Logger) SetVersion(version string) *Logger {
+	logger.Data[versionFieldName] = version
+	return logger
+}
+
+// SetStackTraceLevel sets the least severe level for which the stack trace is captured when an entry
+// is logged (i.e. ErrorLevel). The stack trace is rendered by the %stack% token. The capture is disabled
+// by default (DisabledLevel).
+func (logger *Logger) SetStackTraceLevel(level interface{}) *Logger {
+	logger.hooksMutex.Lock()
+	defer logger.hooksMutex.Unlock()
+	logger.stackLevel = ParseLogLevel(level)
+	return logger.refreshLoggers()
+}
+
+// SetRedactor sets the redactor used to mask secrets in the entries before they are sent to the hooks
+// (except those that have been created with DisableRedaction). The entries are redacted once, before
+// the hooks are fired. The copies of the logger use the same redactor, but setting another redactor
+// on a copy does not affect the original logger. Use nil to disable the redaction.
+func (logger *Logger) SetRedactor(redactor *Redactor) *Logger {
+	logger.hooksMutex.Lock()
+	defer logger.hooksMutex.Unlock()
+	logger.redactor = redactor
+	return logger.refreshLoggers()
+}
+
+// Redactor returns the redactor associated to the logger (if any).
+func (logger *Logger) Redactor() *Redactor {
+	logger.hooksMutex.RLock()
+	defer logger.hooksMutex.RUnlock()
+	return logger.redactor
+}
+
 // IsLevelEnabled checks if the log level of the logger is greater than the level param
 func (logger *Logger) IsLevelEnabled(level logrus.Level) bool {
 	return logger.GetLevel() >= level
@@ -181,8 +339,23 @@ func (logger *Logger) SetReportCaller(reportCaller bool) *Logger {
 }
 
 // SetExitFunc let user define what should be executed when a logging call exit (default is call to os.Exit(int)).
+// The hooks are always flushed before calling the exit function.
 func (logger *Logger) SetExitFunc(exitFunc func(int)) {
-	logger.Logger.ExitFunc = exitFunc
+	logger.hooksMutex.Lock()
+	defer logger.hooksMutex.Unlock()
+	logger.exitFunc = exitFunc
+}
+
+func (logger *Logger) exit(code int) {
+	logger.AddError(logger.Flush())
+	logger.hooksMutex.RLock()
+	exitFunc := logger.exitFunc
+	logger.hooksMutex.RUnlock()
+	if exitFunc != nil {
+		exitFunc(code)
+		return
+	}
+	os.Exit(code)
 }
 
 // AddHook adds a hook to the hook collection and associated it with a name and a level.
@@ -203,6 +376,8 @@ func (logger *Logger) TryAddHook(name string, level interface{}, hook logrus.Hoo
 
 // AddHooks adds a collection of hook wrapper as hook to the current logger.
 func (logger *Logger) AddHooks(hooks ...*Hook) *Logger {
+	logger.hooksMutex.Lock()
+	defer logger.hooksMutex.Unlock()
 	if logger.hooks == nil {
 		logger.hooks = make(map[string]*leveledHook)
 	}
@@ -222,14 +397,27 @@ func (logger *Logger) AddConsole(name string, level interface{}, format ...inter
 }
 
 // AddFile adds a file hook to the current logger.
+// FileOption (i.e. FileMaxSize) can be supplied along with the format arguments.
 func (logger *Logger) AddFile(filename string, isDir bool, level interface{}, format ...interface{}) *Logger {
 	return logger.AddHooks(NewFileHook(filename, isDir, level, format...))
 }
 
 // RemoveHook deletes a hook from the hook collection.
+// The removed hook is closed if it implements io.Closer, errors are reported through AddError.
 func (logger *Logger) RemoveHook(name string) *Logger {
-	delete(logger.hooks, name)
-	return logger.refreshLoggers()
+	logger.hooksMutex.Lock()
+	hook := logger.hooks[name]
+	if hook != nil {
+		delete(logger.hooks, name)
+		logger.refreshLoggers()
+	}
+	logger.hooksMutex.Unlock()
+
+	if hook != nil {
+		// The hook is closed outside of the lock since it may have to wait for pending entries
+		logger.AddError(hook.hook.Close())
+	}
+	return logger
 }
 
 // Hook returns the hook identified by name.
@@ -251,14 +439,27 @@ func (logger *Logger) GetHookLevel(name string) logrus.Level {
 // SetHookLevel set a new log level for a registered hook.
 func (logger *Logger) SetHookLevel(name string, level interface{}) error {
 	if hook := logger.Hook(name); hook != nil {
-		_, err := logger.TryAddHook(hook.name, level, hook.inner)
-		return err
+		level, err := TryParseLogLevel(level)
+		if err != nil {
+			return err
+		}
+		newHook := NewHook(hook.name, level, hook.inner)
+		newHook.noRedaction = hook.noRedaction
+		logger.AddHooks(newHook)
+		return nil
 	}
 	return fmt.Errorf("Hook not found %s", name)
 }
 
 // ListHooks returns the list of registered hook names.
 func (logger *Logger) ListHooks() []string {
+	logger.hooksMutex.RLock()
+	defer logger.hooksMutex.RUnlock()
+	return logger.hookNames()
+}
+
+// hookNames returns the sorted names of the hooks, the caller must hold the hooks mutex.
+func (logger *Logger) hookNames() []string {
 	result := make([]string, 0, len(logger.hooks))
 	for key := range logger.hooks {
 		result = append(result, key)
@@ -267,25 +468,61 @@ func (logger *Logger) ListHooks() []string {
 	return result
 }
 
+// registeredHooks returns the hooks sorted by name.
+func (logger *Logger) registeredHooks() []*Hook {
+	logger.hooksMutex.RLock()
+	defer logger.hooksMutex.RUnlock()
+	result := make([]*Hook, 0, len(logger.hooks))
+	for _, name := range logger.hookNames() {
+		result = append(result, logger.hooks[name].hook)
+	}
+	return result
+}
+
+// refreshLoggers registers the hooks in the logrus logger, the caller must hold the hooks mutex.
+// A new collection is always created since the previous one could be in use by other goroutines.
 func (logger *Logger) refreshLoggers() *Logger {
-	logger.Logger.Hooks = make(logrus.LevelHooks)
+	hooks := make(logrus.LevelHooks)
 	var level logrus.Level
 
-	for _, key := range logger.ListHooks() {
+	if logger.stackLevel != DisabledLevel {
+		// The stack trace must be captured before the other hooks are fired
+		hooks.Add(stackHook{logger.stackLevel})
+	}
+	if logger.redactor != nil {
+		// The entries are redacted once for all the hooks
+		if redaction := logger.redactionHook(); len(redaction.levels) > 0 {
+			hooks.Add(redaction)
+		}
+	}
+
+	for _, key := range logger.hookNames() {
 		hook := logger.hooks[key]
-		logger.Logger.Hooks.Add(hook.hook)
+		hooks.Add(hook.hook)
 		if hook.level > level {
 			level = hook.level
 		}
 	}
+	logger.Logger.ReplaceHooks(hooks)
+	logger.levelHooks = hooks
 	logger.level = level
 	return logger
 }
 
+// fireHooks sends the entry directly to the hooks of the logger (bypassing logrus).
+func (logger *Logger) fireHooks(entry *logrus.Entry) error {
+	logger.hooksMutex.RLock()
+	hooks := logger.levelHooks
+	logger.hooksMutex.RUnlock()
+	return hooks.Fire(entry.Level, entry)
+}
+
 func (logger *Logger) getHook(name string) *leveledHook {
 	if name == "" {
 		name = consoleHookName
 	}
+	logger.hooksMutex.RLock()
+	defer logger.hooksMutex.RUnlock()
 	return logger.hooks[name]
 }
 
@@ -293,28 +530,107 @@ func (logger *Logger) getHook(name string) *leveledHook {
 // wrong in the logging process.
 func (logger *Logger) AddError(err error) {
 	if err != nil {
+		logger.errorsMutex.Lock()
+		defer logger.errorsMutex.Unlock()
 		logger.errors = append(logger.errors, err)
 	}
 }
 
 // GetError returns the current error state of the logging process.
-func (logger *Logger) GetError() error { return logger.errors.AsError() }
+func (logger *Logger) GetError() error { return logger.getErrors().AsError() }
 
 // ClearError cleans up the current error state of the logging process.
 // It also returns the current error state.
 func (logger *Logger) ClearError() error {
+	logger.errorsMutex.Lock()
+	defer logger.errorsMutex.Unlock()
 	current := logger.errors.AsError()
 	logger.errors = nil
 	return current
 }
 
-// Close implements io.Closer
+func (logger *Logger) getErrors() errors.Array {
+	logger.errorsMutex.Lock()
+	defer logger.errorsMutex.Unlock()
+	return logger.errors[:len(logger.errors):len(logger.errors)]
+}
+
+// Flush writes the remaining buffer of the catcher and flushes every hook that supports it.
+func (logger *Logger) Flush() error {
+	return logger.onAllHooks((*Hook).Flush)
+}
+
+// Close implements io.Closer. It writes the remaining buffer of the catcher and closes every
+// hook that implements io.Closer. Closed file hooks are reopened if the logger is used after
+// being closed.
 func (logger *Logger) Close() error {
-	if logger.remaining != "" {
-		_, err := logger.Write(nil)
-		return err
+	return logger.onAllHooks((*Hook).Close)
+}
+
+func (logger *Logger) onAllHooks(action func(*Hook) error) error {
+	var errs errors.Array
+	logger.catcherMutex.Lock()
+	remaining := logger.remaining != ""
+	logger.catcherMutex.Unlock()
+	if remaining {
+		if _, err := logger.Write(nil); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	return nil
+	for _, hook := range logger.registeredHooks() {
+		if err := action(hook); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.AsError()
+}
+
+// Reopen asks every hook that supports it (i.e. file hooks) to reopen its target on the next write.
+func (logger *Logger) Reopen() error {
+	var errs errors.Array
+	for _, hook := range logger.registeredHooks() {
+		if err := hook.Reopen(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.AsError()
+}
+
+// ReopenOnSignal installs a signal handler that calls Reopen when one of the signals is received
+// (default is SIGHUP, as expected by logrotate). The returned function uninstalls the handler.
+func (logger *Logger) ReopenOnSignal(signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	received, done := make(chan os.Signal, 1), make(chan struct{})
+	signal.Notify(received, signals...)
+	go func() {
+		for {
+			select {
+			case <-received:
+				logger.AddError(logger.Reopen())
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(received)
+			close(done)
+		})
+	}
+}
+
+// StdLogger returns a standard library logger that sends its messages to the hooks of the logger.
+// The messages are logged at the specified level unless they contain an embedded [level] marker
+// (i.e. "[error] message"), which is detected by the catcher (see Logger.Write).
+func (logger *Logger) StdLogger(level interface{}) *log.Logger {
+	writer := logger.derive(logger.Entry)
+	writer.PrintLevel = ParseLogLevel(level)
+	writer.Catcher = true
+	return log.New(writer, "", 0)
 }
 
 // This methods intercepts every message written to stream if Catcher is set and determines if a logging
@@ -327,6 +643,10 @@ func (logger *Logger) Write(writeBuffer []byte) (int, error) {
 		return len(writeBuffer), nil
 	}
 
+	// The remaining buffer is shared by all writers
+	logger.catcherMutex.Lock()
+	defer logger.catcherMutex.Unlock()
+
 	var (
 		buffer      string
 		resultCount int
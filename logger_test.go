@@ -6,6 +6,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"testing"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -142,6 +143,36 @@ func ExampleLogger_WithFields() {
 	// [field] 2018/06/24 12:34:56.789 INFO With additional fields hello=world! pi=3.141592653589793.
 }
 
+func ExampleLogger_SetVersion() {
+	log := getTestLogger("versioned", "Trace").SetVersion("v1.2.3")
+
+	// The version is available through the %version% token but it is not included in the fields
+	log.SetFormat("%module:square% %level:upper% %message% (%version%) %fields%.")
+	log.WithField("hello", "world!").Info("Starting")
+	// Output:
+	// [versioned] INFO Starting (v1.2.3) hello=world!.
+}
+
+func ExampleLogger_StdLogger() {
+	log := getTestLogger("std", logrus.InfoLevel)
+
+	// Messages are logged at info level unless they contain a level marker
+	std := log.StdLogger(logrus.InfoLevel)
+	std.Println("Message from a library")
+	std.Printf("[warning] Message with a %s", "marker")
+	std.Print("[debug] Filtered message")
+	// Output:
+	// [std] 2018/06/24 12:34:56.789 INFO     Message from a library
+	// [std] 2018/06/24 12:34:56.789 WARNING  Message with a marker
+}
+
+func ExampleLogger_Slog() {
+	log := getTestLogger("slog", logrus.InfoLevel)
+	log.Slog().Warn("Message from slog")
+	// Output:
+	// [slog] 2018/06/24 12:34:56.789 WARNING  Message from slog
+}
+
 func ExampleLogger_AddConsole() {
 	log := getTestLogger("json")
 
@@ -338,3 +369,27 @@ func ExampleSetDurationPrecision() {
 	// [Rounded] 2021/07/03 00:37:33.450 (3y3w4d) 26508h2m36.661682176s later
 	// [Default] 2021/07/03 00:37:33.450 (26508h2m36.661682176s) 26508h2m36.661682176s later
 }
+
+func BenchmarkLogger_WithField(b *testing.B) {
+	log := New("bench", NewConsoleHook("", logrus.InfoLevel).SetOut(ioutil.Discard), NewFileHook(filepath.Join(b.TempDir(), "bench.log"), false, logrus.InfoLevel))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.WithField("key", i)
+	}
+}
+
+func BenchmarkLogger_Child(b *testing.B) {
+	log := New("bench", NewConsoleHook("", logrus.InfoLevel).SetOut(ioutil.Discard), NewFileHook(filepath.Join(b.TempDir(), "bench.log"), false, logrus.InfoLevel))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.Child("child")
+	}
+}
+
+func BenchmarkLogger_Copy(b *testing.B) {
+	log := New("bench", NewConsoleHook("", logrus.InfoLevel).SetOut(ioutil.Discard), NewFileHook(filepath.Join(b.TempDir(), "bench.log"), false, logrus.InfoLevel))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.Copy("copy")
+	}
+}
@@ -0,0 +1,36 @@
+package multilogger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// Information about the current process that does not change during its execution.
+var (
+	processID       = os.Getpid()
+	processHostname = func() string {
+		hostname, _ := os.Hostname()
+		return hostname
+	}()
+	processExecutable = func() string {
+		if executable, err := os.Executable(); err == nil {
+			return filepath.Base(executable)
+		}
+		return filepath.Base(os.Args[0])
+	}()
+)
+
+// goroutineID returns the identifier of the current goroutine (0 if it cannot be determined).
+// The runtime does not expose it, so we extract it from the header of the stack trace.
+func goroutineID() uint64 {
+	var buffer [64]byte
+	header := bytes.TrimPrefix(buffer[:runtime.Stack(buffer[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(header, ' '); i > 0 {
+		header = header[:i]
+	}
+	id, _ := strconv.ParseUint(string(header), 10, 64)
+	return id
+}
@@ -0,0 +1,291 @@
+package multilogger
+
+import (
+	goerrors "errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/coveooss/multilogger/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultRedactionMask is the string used to replace the secrets.
+const DefaultRedactionMask = "******"
+
+// DefaultRedactionPatterns contains the patterns used by NewRedactor to detect common secrets.
+// If a pattern contains a capturing group, only the captured part is masked.
+var DefaultRedactionPatterns = []string{
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,                                     // AWS access key id
+	`(?i)aws_secret_access_key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`, // AWS secret access key
+	`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`,                            // Bearer token
+	`[a-zA-Z][a-zA-Z0-9+.\-]*://[^:/@\s]+:([^@/\s]+)@`,                  // Password in URL
+}
+
+// Redactor masks secrets in the messages and the fields of the entries before they are sent to the hooks.
+// Secrets are identified by the key of the fields, by regular expressions or by their literal values.
+// A redactor is safe for concurrent use and it can be modified after being attached to a logger.
+type Redactor struct {
+	mutex    sync.RWMutex
+	mask     string
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+	secrets  []string
+}
+
+// NewRedactor creates a redactor that detects the DefaultRedactionPatterns.
+func NewRedactor() *Redactor {
+	return NewEmptyRedactor().AddPatterns(DefaultRedactionPatterns...)
+}
+
+// NewEmptyRedactor creates a redactor without any predefined pattern.
+func NewEmptyRedactor() *Redactor {
+	return &Redactor{mask: DefaultRedactionMask, keys: make(map[string]bool)}
+}
+
+// SetMask sets the string used to replace the secrets.
+func (r *Redactor) SetMask(mask string) *Redactor {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.mask = mask
+	return r
+}
+
+// AddKeys registers field keys (case insensitive) whose values are always masked.
+func (r *Redactor) AddKeys(keys ...string) *Redactor {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, key := range keys {
+		r.keys[strings.ToLower(key)] = true
+	}
+	return r
+}
+
+// AddPatterns registers regular expressions matching secrets.
+// The function will panic if a pattern is invalid.
+func (r *Redactor) AddPatterns(patterns ...string) *Redactor {
+	if err := r.TryAddPatterns(patterns...); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// TryAddPatterns registers regular expressions matching secrets.
+// It returns an error if a pattern is invalid, in that case, no pattern is registered.
+func (r *Redactor) TryAddPatterns(patterns ...string) error {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, re)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.patterns = append(r.patterns, compiled...)
+	return nil
+}
+
+// AddSecrets registers literal secrets (i.e. passwords or tokens known at runtime).
+func (r *Redactor) AddSecrets(secrets ...string) *Redactor {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	return r
+}
+
+// RedactString returns the string with all the secrets masked.
+func (r *Redactor) RedactString(s string) string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.redactString(s)
+}
+
+func (r *Redactor) redactString(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.Replace(s, secret, r.mask, -1)
+	}
+	for _, re := range r.patterns {
+		matches := re.FindAllStringSubmatchIndex(s, -1)
+		for i := len(matches) - 1; i >= 0; i-- {
+			begin, end := matches[i][0], matches[i][1]
+			if len(matches[i]) >= 4 && matches[i][2] >= 0 {
+				// Only the first capturing group is masked
+				begin, end = matches[i][2], matches[i][3]
+			}
+			s = s[:begin] + r.mask + s[end:]
+		}
+	}
+	return s
+}
+
+// Redact returns the entry with all the secrets masked. The original entry is never modified,
+// a copy is returned if any secret has been found.
+//
+// Errors are wrapped to mask their message and the message of their causes. Other values that
+// are not strings (i.e. maps, structs or slices) are rendered as strings if they contain secrets.
+func (r *Redactor) Redact(entry *logrus.Entry) *logrus.Entry {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result *logrus.Entry
+	update := func() *logrus.Entry {
+		if result == nil {
+			result = copyEntry(entry)
+		}
+		return result
+	}
+	if message := r.redactString(entry.Message); message != entry.Message {
+		update().Message = message
+	}
+	for key, value := range entry.Data {
+		if isInternalField(key) {
+			continue
+		}
+		if r.keys[strings.ToLower(key)] {
+			update().Data[key] = r.mask
+			continue
+		}
+		if err, isError := value.(error); isError {
+			if r.errorHasSecret(err) {
+				update().Data[key] = r.redactError(err)
+			}
+			continue
+		}
+		var original string
+		switch value := value.(type) {
+		case string:
+			original = value
+		case fmt.Stringer:
+			original = value.String()
+		default:
+			switch reflect.ValueOf(value).Kind() {
+			case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array, reflect.Ptr:
+				original = fmt.Sprint(value)
+			default:
+				continue
+			}
+		}
+		if redacted := r.redactString(original); redacted != original {
+			update().Data[key] = redacted
+		}
+	}
+	if result == nil {
+		return entry
+	}
+	return result
+}
+
+// errorHasSecret checks if the message of the error or the message of one of its causes contains a secret.
+func (r *Redactor) errorHasSecret(err error) bool {
+	if message := err.Error(); r.redactString(message) != message {
+		return true
+	}
+	var causes []error
+	switch wrapper := err.(type) {
+	case errors.Array:
+		causes = wrapper
+	case interface{ Unwrap() []error }:
+		causes = wrapper.Unwrap()
+	case interface{ Unwrap() error }:
+		causes = []error{wrapper.Unwrap()}
+	}
+	for _, cause := range causes {
+		if cause != nil && r.errorHasSecret(cause) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactError wraps the error to mask the secrets in its message and in the message of its causes.
+func (r *Redactor) redactError(err error) error {
+	switch wrapper := err.(type) {
+	case errors.Array:
+		result := make(errors.Array, len(wrapper))
+		for i, inner := range wrapper {
+			if inner != nil {
+				result[i] = r.redactError(inner)
+			}
+		}
+		return result
+	case interface{ Unwrap() []error }:
+		return redactedErrors{redactedError{err, r}}
+	}
+	return redactedError{err, r}
+}
+
+// redactedError is an error whose message and causes are masked by the redactor. The original error
+// is still available through errors.Is and errors.As.
+type redactedError struct {
+	err      error
+	redactor *Redactor
+}
+
+func (e redactedError) Error() string              { return e.redactor.RedactString(e.err.Error()) }
+func (e redactedError) Is(target error) bool       { return goerrors.Is(e.err, target) }
+func (e redactedError) As(target interface{}) bool { return goerrors.As(e.err, target) }
+func (e redactedError) Unwrap() error {
+	if inner := goerrors.Unwrap(e.err); inner != nil {
+		return e.redactor.redactError(inner)
+	}
+	return nil
+}
+
+// redactedErrors is a redacted error that wraps several errors.
+type redactedErrors struct {
+	redactedError
+}
+
+func (e redactedErrors) Unwrap() []error {
+	inner := e.err.(interface{ Unwrap() []error }).Unwrap()
+	result := make([]error, 0, len(inner))
+	for _, err := range inner {
+		if err != nil {
+			result = append(result, e.redactor.redactError(err))
+		}
+	}
+	return result
+}
+
+// redactionHook is an internal hook that redacts the entries before the other hooks are fired. The redacted
+// copy is stored in a hidden field, so the hooks created with DisableRedaction still get the original entry.
+type redactionHook struct {
+	redactor *Redactor
+	levels   []logrus.Level
+}
+
+func (hook redactionHook) Levels() []logrus.Level { return hook.levels }
+
+func (hook redactionHook) Fire(entry *logrus.Entry) error {
+	if redacted := hook.redactor.Redact(entry); redacted != entry {
+		entry.Data[redactedFieldName] = redacted
+	}
+	return nil
+}
+
+// redactionHook returns the hook that redacts the entries of the levels handled by the hooks that do not
+// disable the redaction, the caller must hold the hooks mutex.
+func (logger *Logger) redactionHook() redactionHook {
+	result := redactionHook{redactor: logger.redactor}
+	registered := make(map[logrus.Level]bool)
+	for _, hook := range logger.hooks {
+		if hook.hook.noRedaction {
+			continue
+		}
+		for _, level := range hook.hook.Levels() {
+			if !registered[level] {
+				registered[level] = true
+				result.levels = append(result.levels, level)
+			}
+		}
+	}
+	return result
+}
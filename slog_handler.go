@@ -0,0 +1,186 @@
+package multilogger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Additional slog levels that map to logrus levels that do not exist in slog.
+const (
+	// SlogTraceLevel is mapped to logrus.TraceLevel (as all levels below slog.LevelDebug).
+	SlogTraceLevel = slog.LevelDebug - 4
+	// SlogFatalLevel is mapped to logrus.FatalLevel (the handler does not exit).
+	SlogFatalLevel = slog.LevelError + 4
+	// SlogPanicLevel is mapped to logrus.PanicLevel (the handler does not panic).
+	SlogPanicLevel = slog.LevelError + 8
+	// SlogPrintLevel is mapped to the PrintLevel of the logger (by default, the message is printed without decoration).
+	SlogPrintLevel = slog.LevelError + 100
+)
+
+// SlogHandler is a slog.Handler that sends the records to the hooks of a Logger.
+//
+// Attributes are converted into fields (attributes of groups are prefixed by the group name) and groups
+// created by WithGroup are converted into child modules (see Logger.Child).
+type SlogHandler struct {
+	logger *Logger
+	attrs  slogFields
+}
+
+// NewSlogHandler creates a slog.Handler that sends the records to the hooks of the logger.
+func NewSlogHandler(logger *Logger) *SlogHandler {
+	return &SlogHandler{logger: logger}
+}
+
+// Slog returns a slog.Logger that sends its records to the hooks of the logger (see SlogHandler).
+func (logger *Logger) Slog() *slog.Logger {
+	return slog.New(NewSlogHandler(logger))
+}
+
+// Logger returns the logger used by the handler.
+func (h *SlogHandler) Logger() *Logger { return h.logger }
+
+// Enabled indicates if at least one hook accepts the level (the output level is always accepted).
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	mapped := h.level(level)
+	return mapped == outputLevel || h.logger.IsLevelEnabled(mapped)
+}
+
+// Handle sends the record to the hooks of the logger. The hooks are fired directly (instead of going
+// through logrus) to preserve the source of the record which is used as caller.
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := h.attrs.copy(record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs.add("", attr)
+		return true
+	})
+
+	// We always work on a copy since the entry of the logger could be used by other goroutines
+	var entry *logrus.Entry
+	if len(attrs.fields) > 0 {
+		if h.logger.tracksInsertionOrder() {
+			attrs.fields[orderFieldName] = attrs.insertionOrder(h.logger.Data)
+		}
+		entry = h.logger.Entry.WithFields(attrs.fields)
+	} else {
+		entry = h.logger.Entry.Dup()
+	}
+	if ctx != nil {
+		entry.Context = ctx
+	}
+	if entry.Time.IsZero() {
+		// The time is only kept if the logger time has been frozen (i.e. WithTime)
+		entry.Time = record.Time
+	}
+	entry.Level = h.level(record.Level)
+	entry.Message = record.Message
+	if h.logger.Logger.ReportCaller && record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		entry.Caller = &frame
+	}
+
+	if err := h.logger.fireHooks(entry); err != nil {
+		// We report the error the same way logrus does
+		fmt.Fprintf(os.Stderr, "Failed to fire hook: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// WithAttrs returns a new handler that adds the attributes as fields to all records.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	result := &SlogHandler{logger: h.logger, attrs: h.attrs.copy(len(attrs))}
+	for _, attr := range attrs {
+		result.attrs.add("", attr)
+	}
+	return result
+}
+
+// WithGroup returns a new handler that uses a child logger of the current one (see Logger.Child).
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{logger: h.logger.Child(name), attrs: h.attrs}
+}
+
+// level converts the slog level into a logrus level.
+func (h *SlogHandler) level(level slog.Level) logrus.Level {
+	switch {
+	case level == SlogPrintLevel:
+		return h.logger.PrintLevel
+	case level >= SlogPanicLevel:
+		return logrus.PanicLevel
+	case level >= SlogFatalLevel:
+		return logrus.FatalLevel
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	case level >= slog.LevelDebug:
+		return logrus.DebugLevel
+	default:
+		return logrus.TraceLevel
+	}
+}
+
+// slogFields holds the fields converted from slog attributes in the order they have been added.
+type slogFields struct {
+	fields logrus.Fields
+	order  []string
+}
+
+func (f slogFields) copy(capacity int) slogFields {
+	result := slogFields{
+		fields: make(logrus.Fields, len(f.fields)+capacity),
+		order:  make([]string, len(f.order), len(f.order)+capacity),
+	}
+	for key, value := range f.fields {
+		result.fields[key] = value
+	}
+	copy(result.order, f.order)
+	return result
+}
+
+// add adds the attribute to the fields, attributes of groups are flattened.
+func (f *slogFields) add(prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, inner := range attr.Value.Group() {
+			f.add(prefix, inner)
+		}
+		return
+	}
+	key := prefix + attr.Key
+	if _, isSet := f.fields[key]; !isSet {
+		f.order = append(f.order, key)
+	}
+	f.fields[key] = attr.Value.Any()
+}
+
+// insertionOrder returns the insertion order of the fields after adding the attributes to the existing fields.
+func (f slogFields) insertionOrder(existing logrus.Fields) []string {
+	previous, _ := existing[orderFieldName].([]string)
+	result := append(make([]string, 0, len(previous)+len(f.order)), previous...)
+	for _, key := range f.order {
+		if _, isSet := existing[key]; !isSet && !isInternalField(key) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
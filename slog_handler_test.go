@@ -0,0 +1,96 @@
+package multilogger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleNewSlogHandler() {
+	log := getTestLogger("slog", logrus.InfoLevel)
+	log.SetFormat("%module:square% %level:upper% %message% %fields%")
+	log.Formatter().InsertionOrder = true
+
+	logger := slog.New(NewSlogHandler(log)).With("request", 42)
+	logger.Info("Hello", "user", "john", slog.Group("http", "method", "GET", "status", 200))
+	logger.WithGroup("db").Warn("Slow query", "table", "users")
+	logger.Debug("Not logged")
+	logger.Log(context.Background(), SlogPrintLevel, "Printed without decoration")
+	// Output:
+	// [slog] INFO Hello request=42 user=john http.method=GET http.status=200
+	// [slog:db] WARNING Slow query request=42 table=users
+	// Printed without decoration
+}
+
+func TestSlogHandler(t *testing.T) {
+	t.Parallel()
+
+	var buffer bytes.Buffer
+	log := New("slog", NewConsoleHook("", logrus.TraceLevel).SetColor(false).SetOut(&buffer))
+	log.SetReportCaller(true)
+	log.SetFormat("%level% %caller:short,base% %message%")
+	handler := NewSlogHandler(log)
+	logger := slog.New(handler)
+
+	tests := []struct {
+		level slog.Level
+		want  logrus.Level
+	}{
+		{SlogTraceLevel, logrus.TraceLevel},
+		{slog.LevelDebug, logrus.DebugLevel},
+		{slog.LevelInfo, logrus.InfoLevel},
+		{slog.LevelInfo + 2, logrus.InfoLevel},
+		{slog.LevelWarn, logrus.WarnLevel},
+		{slog.LevelError, logrus.ErrorLevel},
+		{SlogFatalLevel, logrus.FatalLevel},
+		{SlogPanicLevel, logrus.PanicLevel},
+		{SlogPrintLevel, outputLevel},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, handler.level(tt.level), tt.level.String())
+	}
+
+	logger.Error("Test caller")
+	assert.Equal(t, "error multilogger.TestSlogHandler slog_handler_test.go:59 Test caller\n", buffer.String())
+
+	// Fatal and panic levels are logged without exiting or panicking
+	buffer.Reset()
+	assert.NotPanics(t, func() { logger.Log(context.Background(), SlogPanicLevel, "Test panic") })
+	assert.Contains(t, buffer.String(), "panic")
+
+	assert.True(t, handler.Enabled(context.Background(), SlogPrintLevel))
+	log.SetHookLevel("", logrus.WarnLevel)
+	assert.False(t, handler.Enabled(context.Background(), slog.LevelInfo))
+	assert.True(t, handler.Enabled(context.Background(), slog.LevelWarn))
+	assert.True(t, handler.Enabled(context.Background(), SlogPrintLevel))
+}
+
+func TestSlogHandler_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	var buffer syncBuffer
+	log := New("slog", NewConsoleHook("", logrus.InfoLevel, "%message%").SetOut(&buffer))
+	handler := NewSlogHandler(log)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The handler could be called without context
+			assert.NoError(t, handler.Handle(nil, slog.NewRecord(time.Now(), slog.LevelInfo, "Hello", 0)))
+		}()
+	}
+	wg.Wait()
+
+	// The records are sent without modifying the entry of the logger
+	assert.Equal(t, 10, buffer.Lines())
+	assert.True(t, log.Time.IsZero())
+	assert.Empty(t, log.Message)
+}